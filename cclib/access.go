@@ -0,0 +1,202 @@
+package cclib
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"path"
+	"strings"
+)
+
+// ErrOwnerNotRevoked is the error of the AccessChanges returned by
+// RevokeAccess for the applications owned by the user, since an
+// owner can not be removed
+var ErrOwnerNotRevoked = errors.New("Owner access can not be revoked.")
+
+// AccessChange contains the result of granting or revoking
+// a user access to an application or deployment
+type AccessChange struct {
+	Application string
+	// Deployment is empty for application wide access
+	Deployment string
+	Role       string
+	// Err is set if the change could not be applied
+	Err error
+}
+
+// AccessEntry contains the role of a user
+// on an application or deployment
+type AccessEntry struct {
+	Username    string
+	Email       string
+	Application string
+	// Deployment is empty for application wide access
+	Deployment string
+	Role       string
+}
+
+// AccessMatrix contains who has which role where
+type AccessMatrix []AccessEntry
+
+// GrantAccess adds a user to every application or deployment
+// matching a pattern having:
+//
+// * Pattern, eg: `shop-*` matches application names and
+// `shop-*/prod*` matches application/deployment names.
+// See path.Match for the syntax.
+//
+// * User email
+//
+// * User role, optional
+//
+// Returns the list of AccessChanges, each one with its own error,
+// and an error if the applications can not be read.
+func (api *API) GrantAccess(pattern, userEmail, role string) (_ []AccessChange, err error) {
+	op := api.begin("GrantAccess")
+	defer func() { op.end(err) }()
+	api = api.within(op)
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	apps, err := api.ReadApplications()
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []AccessChange
+	appPattern, depPattern := splitAccessPattern(pattern)
+	for _, app := range *apps {
+		if ok, _ := path.Match(appPattern, app.Name); !ok {
+			continue
+		}
+
+		if depPattern == "" {
+			_, err := api.CreateAppUser(app.Name, userEmail, role)
+			changes = append(changes, AccessChange{app.Name, "", role, err})
+			continue
+		}
+
+		deployments, err := api.ReadDeployments(app.Name)
+		if err != nil {
+			changes = append(changes, AccessChange{app.Name, depPattern, role, err})
+			continue
+		}
+
+		for _, dep := range *deployments {
+			depName := deploymentName(dep.Name)
+			if ok, _ := path.Match(depPattern, depName); !ok {
+				continue
+			}
+			_, err := api.CreateDeploymentUser(app.Name, depName, userEmail, role)
+			changes = append(changes, AccessChange{app.Name, depName, role, err})
+		}
+	}
+
+	return changes, nil
+}
+
+// RevokeAccess removes a user from every application and
+// deployment having:
+//
+// * User name or email
+//
+// Owner entries are not removed, their AccessChanges have
+// ErrOwnerNotRevoked as error, so the user may still have access.
+//
+// Returns the list of AccessChanges, each one with its own error,
+// and an error if the applications can not be read.
+func (api *API) RevokeAccess(user string) (_ []AccessChange, err error) {
+	op := api.begin("RevokeAccess")
+	defer func() { op.end(err) }()
+	api = api.within(op)
+
+	matrix, err := api.ReadAccessMatrix()
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []AccessChange
+	for _, entry := range matrix {
+		if entry.Username != user && entry.Email != user {
+			continue
+		}
+		if entry.Role == "owner" {
+			changes = append(changes, AccessChange{entry.Application, entry.Deployment, entry.Role, ErrOwnerNotRevoked})
+			continue
+		}
+
+		if entry.Deployment == "" {
+			err = api.DeleteAppUser(entry.Application, entry.Username)
+		} else {
+			err = api.DeleteDeploymentUser(entry.Application, entry.Deployment, entry.Username)
+		}
+		changes = append(changes, AccessChange{entry.Application, entry.Deployment, entry.Role, err})
+	}
+
+	return changes, nil
+}
+
+// ReadAccessMatrix reads the users of every application
+// and deployment of the current user.
+//
+// Returns the AccessMatrix
+// and an error if any request does not success.
+func (api *API) ReadAccessMatrix() (_ AccessMatrix, err error) {
+	op := api.begin("ReadAccessMatrix")
+	defer func() { op.end(err) }()
+	api = api.within(op)
+
+	apps, err := api.ReadApplications()
+	if err != nil {
+		return nil, err
+	}
+
+	var matrix AccessMatrix
+	for _, app := range *apps {
+		users, err := api.ReadAppUsers(app.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, user := range *users {
+			matrix = append(matrix, AccessEntry{user.Username, user.Email, app.Name, "", user.Role})
+		}
+
+		deployments, err := api.ReadDeployments(app.Name)
+		if err != nil {
+			return nil, err
+		}
+		for _, dep := range *deployments {
+			depName := deploymentName(dep.Name)
+			users, err := api.ReadDeploymentUsers(app.Name, depName)
+			if err != nil {
+				return nil, err
+			}
+			for _, user := range *users {
+				matrix = append(matrix, AccessEntry{user.Username, user.Email, app.Name, depName, user.Role})
+			}
+		}
+	}
+
+	return matrix, nil
+}
+
+// WriteCSV writes the access matrix in csv format
+func (matrix AccessMatrix) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"username", "email", "application", "deployment", "role"})
+	for _, entry := range matrix {
+		writer.Write([]string{entry.Username, entry.Email, entry.Application, entry.Deployment, entry.Role})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func splitAccessPattern(pattern string) (appPattern, depPattern string) {
+	if i := strings.Index(pattern, "/"); i >= 0 {
+		return pattern[:i], pattern[i+1:]
+	}
+	return pattern, ""
+}
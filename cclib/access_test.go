@@ -0,0 +1,128 @@
+package cclib
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func accessTestHandler(requests *[]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			*requests = append(*requests, r.Method+" "+r.URL.Path)
+			fmt.Fprint(w, `{}`)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/app/":
+			fmt.Fprint(w, `[{"name": "shop-eu"}, {"name": "shop-us"}, {"name": "blog"}]`)
+		case "/app/shop-eu/deployment/", "/app/shop-us/deployment/", "/app/blog/deployment/":
+			fmt.Fprint(w, `[{"name": "x/default"}, {"name": "x/production"}]`)
+		case "/app/shop-eu/user/":
+			fmt.Fprint(w, `[{"username": "anna", "email": "anna@example.org", "role": "owner"},
+				{"username": "john", "email": "john@example.org", "role": "admin"}]`)
+		case "/app/blog/deployment/production/user/":
+			fmt.Fprint(w, `[{"username": "john", "email": "john@example.org", "role": "readonly"}]`)
+		default:
+			fmt.Fprint(w, `[]`)
+		}
+	}
+}
+
+func TestGrantAccess(t *testing.T) {
+	// Given
+	var requests []string
+	api, server := newTestAPI(accessTestHandler(&requests))
+	defer server.Close()
+
+	// When
+	appChanges, errApps := api.GrantAccess("shop-*", "john@example.org", "admin")
+	depChanges, errDeps := api.GrantAccess("shop-eu/prod*", "john@example.org", "readonly")
+
+	// Then
+	if errApps != nil || errDeps != nil {
+		t.Errorf(msgFail, "GrantAccess", nil, []error{errApps, errDeps})
+	}
+	if len(appChanges) != 2 || len(depChanges) != 1 || depChanges[0].Deployment != "production" {
+		t.Errorf(msgFail, "GrantAccess", "3 changes", append(appChanges, depChanges...))
+	}
+	expected := []string{
+		"POST /app/shop-eu/user/",
+		"POST /app/shop-us/user/",
+		"POST /app/shop-eu/deployment/production/user/",
+	}
+	if !reflect.DeepEqual(requests, expected) {
+		t.Errorf(msgFail, "GrantAccess", expected, requests)
+	}
+}
+
+func TestRevokeAccess(t *testing.T) {
+	// Given
+	var requests []string
+	api, server := newTestAPI(accessTestHandler(&requests))
+	defer server.Close()
+
+	// When
+	changes, err := api.RevokeAccess("john@example.org")
+
+	// Then
+	if err != nil {
+		t.Errorf(msgFail, "RevokeAccess", nil, err)
+	}
+	expected := []string{
+		"DELETE /app/shop-eu/user/john/",
+		"DELETE /app/blog/deployment/production/user/john/",
+	}
+	if !reflect.DeepEqual(requests, expected) || len(changes) != 2 {
+		t.Errorf(msgFail, "RevokeAccess", expected, requests)
+	}
+}
+
+func TestRevokeAccessOwner(t *testing.T) {
+	// Given
+	var requests []string
+	api, server := newTestAPI(accessTestHandler(&requests))
+	defer server.Close()
+
+	// When
+	changes, err := api.RevokeAccess("anna")
+
+	// Then
+	if err != nil {
+		t.Errorf(msgFail, "RevokeAccess owner", nil, err)
+	}
+	if len(requests) != 0 {
+		t.Errorf(msgFail, "RevokeAccess owner requests", 0, requests)
+	}
+	expected := []AccessChange{{"shop-eu", "", "owner", ErrOwnerNotRevoked}}
+	if !reflect.DeepEqual(changes, expected) {
+		t.Errorf(msgFail, "RevokeAccess owner", expected, changes)
+	}
+}
+
+func TestAccessMatrixWriteCSV(t *testing.T) {
+	// Given
+	var requests []string
+	api, server := newTestAPI(accessTestHandler(&requests))
+	defer server.Close()
+	expected := "username,email,application,deployment,role\n" +
+		"anna,anna@example.org,shop-eu,,owner\n" +
+		"john,john@example.org,shop-eu,,admin\n" +
+		"john,john@example.org,blog,production,readonly\n"
+
+	// When
+	matrix, err := api.ReadAccessMatrix()
+	var buf bytes.Buffer
+	matrix.WriteCSV(&buf)
+
+	// Then
+	if err != nil {
+		t.Errorf(msgFail, "ReadAccessMatrix", nil, err)
+	}
+	if buf.String() != expected {
+		t.Errorf(msgFail, "AccessMatrix.WriteCSV", expected, buf.String())
+	}
+}
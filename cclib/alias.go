@@ -0,0 +1,203 @@
+package cclib
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strings"
+	"time"
+)
+
+// AliasVerificationPrefix precedes the verification
+// code in the alias TXT record
+var AliasVerificationPrefix = "cloudControl-verification: "
+
+// DNSResolver is an interface that defines the DNS lookups
+// needed to check alias records. It allows stubbing DNS in tests.
+type DNSResolver interface {
+	LookupTXT(name string) ([]string, error)
+	LookupCNAME(name string) (string, error)
+}
+
+// netResolver implements DNSResolver using the net package
+type netResolver struct{}
+
+func (netResolver) LookupTXT(name string) ([]string, error) {
+	return net.LookupTXT(name)
+}
+
+func (netResolver) LookupCNAME(name string) (string, error) {
+	return net.LookupCNAME(name)
+}
+
+// DNSRecord contains a DNS record required by an alias
+type DNSRecord struct {
+	// TXT or CNAME
+	Type  string
+	Name  string
+	Value string
+}
+
+func (record DNSRecord) String() string {
+	return fmt.Sprintf("%s. IN %s %q", strings.TrimSuffix(record.Name, "."), record.Type, record.Value)
+}
+
+// AliasRecords returns the DNS records an alias requires
+// to point to a deployment and to be verified
+func AliasRecords(alias *Alias, deployment *Deployment) []DNSRecord {
+	return []DNSRecord{
+		{"TXT", alias.Name, AliasVerificationPrefix + alias.VerificationCode},
+		{"CNAME", alias.Name, strings.TrimSuffix(deployment.DefaultSubdomain, ".") + "."},
+	}
+}
+
+// CheckAliasRecords looks up the given records with the resolver.
+// Returns a list of mismatch diagnostics, empty if every record is set.
+func CheckAliasRecords(resolver DNSResolver, records []DNSRecord) []string {
+	var problems []string
+
+	for _, record := range records {
+		switch record.Type {
+		case "TXT":
+			values, err := resolver.LookupTXT(record.Name)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("TXT lookup for %s failed: %v", record.Name, err))
+				continue
+			}
+			if !containsString(values, record.Value) {
+				problems = append(problems, fmt.Sprintf("TXT record for %s expected %q, found %q", record.Name, record.Value, values))
+			}
+		case "CNAME":
+			value, err := resolver.LookupCNAME(record.Name)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("CNAME lookup for %s failed: %v", record.Name, err))
+				continue
+			}
+			if !strings.EqualFold(strings.TrimSuffix(value, "."), strings.TrimSuffix(record.Value, ".")) {
+				problems = append(problems, fmt.Sprintf("CNAME record for %s expected %q, found %q", record.Name, record.Value, value))
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("Record type %s for %s is not supported", record.Type, record.Name))
+		}
+	}
+
+	return problems
+}
+
+// AliasVerificationError is returned when an alias is not
+// verified before the timeout
+type AliasVerificationError struct {
+	Alias *Alias
+	// Problems contains the last DNS mismatch diagnostics
+	Problems []string
+}
+
+func (e *AliasVerificationError) Error() string {
+	msg := fmt.Sprintf("Alias %s was not verified", e.Alias.Name)
+	if e.Alias.VerificationErrors > 0 {
+		msg += fmt.Sprintf(" after %d verification errors", e.Alias.VerificationErrors)
+	}
+	if len(e.Problems) > 0 {
+		msg += ": " + strings.Join(e.Problems, "; ")
+	}
+	return msg + "."
+}
+
+// AliasVerifier guides the creation of a custom domain alias
+// until the API reports it as verified
+type AliasVerifier struct {
+	Api      *API
+	Resolver DNSResolver
+	// Out receives the required records and the check progress
+	Out io.Writer
+	// Interval between two checks
+	Interval time.Duration
+	// Timeout to wait for the alias to be verified
+	Timeout time.Duration
+}
+
+// NewAliasVerifier creates an AliasVerifier for an API which
+// resolves DNS with the net package and writes nowhere.
+//
+// Returns a new AliasVerifier pointer
+func NewAliasVerifier(api *API) *AliasVerifier {
+	return &AliasVerifier{
+		Api:      api,
+		Resolver: netResolver{},
+		Out:      ioutil.Discard,
+		Interval: 30 * time.Second,
+		Timeout:  time.Hour,
+	}
+}
+
+// Verify creates an alias and waits until it is verified having:
+//
+// * Application name
+//
+// * Alias name
+//
+// * Deployment name
+//
+// The required DNS records are written to Out, then the alias
+// state is read every Interval until the API reports it verified.
+// DNS is checked locally at the same time, and its problems are
+// written to Out as diagnostics only, since the local resolver may
+// be stale or see other records than the platform.
+//
+// Returns the verified Alias and an error if any request does not
+// success or an *AliasVerificationError if Timeout is reached.
+func (verifier *AliasVerifier) Verify(appName, aliasName, depName string) (*Alias, error) {
+	alias, err := verifier.Api.CreateAlias(appName, aliasName, depName)
+	if err != nil {
+		return nil, err
+	}
+
+	deployment, err := verifier.Api.ReadDeployment(appName, depName)
+	if err != nil {
+		return nil, err
+	}
+
+	records := AliasRecords(alias, deployment)
+	fmt.Fprintf(verifier.Out, "Add the following DNS records for %s:\n", alias.Name)
+	for _, record := range records {
+		fmt.Fprintf(verifier.Out, "  %s\n", record)
+	}
+
+	deadline := time.Now().Add(verifier.Timeout)
+	var problems []string
+	for {
+		current := CheckAliasRecords(verifier.Resolver, records)
+		if strings.Join(current, "\n") != strings.Join(problems, "\n") {
+			for _, problem := range current {
+				fmt.Fprintf(verifier.Out, "  %s\n", problem)
+			}
+			if len(current) == 0 {
+				fmt.Fprintln(verifier.Out, "DNS records found, waiting for verification.")
+			}
+		}
+		problems = current
+
+		if alias, err = verifier.Api.ReadAlias(appName, aliasName, depName); err != nil {
+			return nil, err
+		}
+		if alias.IsVerified {
+			fmt.Fprintf(verifier.Out, "Alias %s is verified.\n", alias.Name)
+			return alias, nil
+		}
+
+		if !time.Now().Add(verifier.Interval).Before(deadline) {
+			return nil, &AliasVerificationError{alias, problems}
+		}
+		time.Sleep(verifier.Interval)
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,159 @@
+package cclib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type stubResolver struct {
+	txt   map[string][]string
+	cname map[string]string
+}
+
+func (r stubResolver) LookupTXT(name string) ([]string, error) {
+	if values, ok := r.txt[name]; ok {
+		return values, nil
+	}
+	return nil, errors.New("no such host")
+}
+
+func (r stubResolver) LookupCNAME(name string) (string, error) {
+	if value, ok := r.cname[name]; ok {
+		return value, nil
+	}
+	return "", errors.New("no such host")
+}
+
+func TestCheckAliasRecords(t *testing.T) {
+	// Given
+	alias := &Alias{Name: "www.example.com", VerificationCode: "abc"}
+	deployment := &Deployment{DefaultSubdomain: "app.cloudcontrolled.com"}
+	records := AliasRecords(alias, deployment)
+	resolver := stubResolver{
+		txt:   map[string][]string{"www.example.com": {"cloudControl-verification: xyz"}},
+		cname: map[string]string{"www.example.com": "APP.cloudcontrolled.com."},
+	}
+	expectedProblems := []string{
+		`TXT record for www.example.com expected "cloudControl-verification: abc", found ["cloudControl-verification: xyz"]`,
+	}
+
+	// When
+	problems := CheckAliasRecords(resolver, records)
+
+	// Then
+	if !reflect.DeepEqual(problems, expectedProblems) {
+		t.Errorf(msgFail, "CheckAliasRecords", expectedProblems, problems)
+	}
+}
+
+func TestAliasVerifierVerify(t *testing.T) {
+	// Given
+	reads := 0
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST":
+			fmt.Fprint(w, `{"name": "www.example.com", "verification_code": "abc"}`)
+		case strings.Contains(r.URL.Path, "/alias/"):
+			reads++
+			fmt.Fprintf(w, `{"name": "www.example.com", "verification_code": "abc", "is_verified": %v}`, reads > 1)
+		default:
+			fmt.Fprint(w, `{"name": "app/default", "default_subdomain": "app.cloudcontrolled.com"}`)
+		}
+	})
+	defer server.Close()
+
+	var out bytes.Buffer
+	verifier := NewAliasVerifier(api)
+	verifier.Out = &out
+	verifier.Interval = time.Millisecond
+	verifier.Timeout = time.Second
+	verifier.Resolver = stubResolver{
+		txt:   map[string][]string{"www.example.com": {"cloudControl-verification: abc"}},
+		cname: map[string]string{"www.example.com": "app.cloudcontrolled.com."},
+	}
+
+	// When
+	alias, err := verifier.Verify("app", "www.example.com", "default")
+
+	// Then
+	if err != nil {
+		t.Fatalf(msgFail, "Verify", nil, err)
+	}
+	if !alias.IsVerified {
+		t.Errorf(msgFail, "Verify", true, alias.IsVerified)
+	}
+	if !strings.Contains(out.String(), `www.example.com. IN TXT "cloudControl-verification: abc"`) {
+		t.Errorf(msgFail, "Verify", "TXT record", out.String())
+	}
+}
+
+func TestAliasVerifierVerifyStaleDNS(t *testing.T) {
+	// Given
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST":
+			fmt.Fprint(w, `{"name": "www.example.com", "verification_code": "abc"}`)
+		case strings.Contains(r.URL.Path, "/alias/"):
+			fmt.Fprint(w, `{"name": "www.example.com", "verification_code": "abc", "is_verified": true}`)
+		default:
+			fmt.Fprint(w, `{"name": "app/default", "default_subdomain": "app.cloudcontrolled.com"}`)
+		}
+	})
+	defer server.Close()
+
+	var out bytes.Buffer
+	verifier := NewAliasVerifier(api)
+	verifier.Out = &out
+	verifier.Interval = time.Millisecond
+	verifier.Timeout = time.Second
+	verifier.Resolver = stubResolver{}
+
+	// When
+	alias, err := verifier.Verify("app", "www.example.com", "default")
+
+	// Then
+	if err != nil {
+		t.Fatalf(msgFail, "Verify with stale DNS", nil, err)
+	}
+	if !alias.IsVerified {
+		t.Errorf(msgFail, "Verify with stale DNS", true, alias.IsVerified)
+	}
+	if !strings.Contains(out.String(), "TXT lookup for www.example.com") && !strings.Contains(out.String(), "TXT record for www.example.com") {
+		t.Errorf(msgFail, "Verify with stale DNS diagnostics", "TXT problem", out.String())
+	}
+}
+
+func TestAliasVerifierTimeout(t *testing.T) {
+	// Given
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, "/alias/") || r.Method == "POST" {
+			fmt.Fprint(w, `{"name": "www.example.com", "verification_code": "abc"}`)
+			return
+		}
+		fmt.Fprint(w, `{"name": "app/default", "default_subdomain": "app.cloudcontrolled.com"}`)
+	})
+	defer server.Close()
+
+	verifier := NewAliasVerifier(api)
+	verifier.Interval = time.Millisecond
+	verifier.Timeout = 5 * time.Millisecond
+	verifier.Resolver = stubResolver{}
+
+	// When
+	_, err := verifier.Verify("app", "www.example.com", "default")
+
+	// Then
+	verificationErr, ok := err.(*AliasVerificationError)
+	if !ok {
+		t.Fatalf(msgFail, "Verify", "*AliasVerificationError", err)
+	}
+	if len(verificationErr.Problems) != 2 {
+		t.Errorf(msgFail, "Verify", 2, verificationErr.Problems)
+	}
+}
@@ -65,12 +65,14 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"net/url"
 	"os"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
-
-	ms "github.com/mitchellh/mapstructure"
 )
 
 // Api is an interface than defines basic HTTP
@@ -92,12 +94,28 @@ type Api interface {
 // An API is the entity that make calls and manage
 // the cloudControl programming interface.
 // It implements the Api interface.
+//
+// An API is safe for concurrent use by multiple goroutines.
+// Its token can be set, cleared or refreshed at any time, but
+// its URLs, like SSL_CHECK and CA_CERTS, must be set before
+// sharing it: requests made while they change may use the
+// previous values, the new ones or a mix of both.
 type API struct {
+	mu               sync.RWMutex
 	cache            string
 	url              string
-	token            *Token
+	token            *tokenHolder
 	tokenSourceUrl   string
 	registerAddonUrl string
+	middlewares      []Middleware
+	call             *operation
+	served           *int32
+	tracer           Tracer
+	collector        Collector
+	transport        http.RoundTripper
+	plan             *Plan
+	journal          *DeployJournal
+	notifier         *Notifier
 }
 
 // NewAPI creates a default new API instance.
@@ -119,57 +137,120 @@ func NewCustomAPI(url string, token *Token, tokenSourceUrl string, registerAddon
 		registerAddonUrl = fmt.Sprintf("%s%s", url, "/provider/addons")
 	}
 
-	return &API{CACHE, url, token, tokenSourceUrl, registerAddonUrl}
+	return &API{
+		cache:            CACHE,
+		url:              url,
+		token:            &tokenHolder{token: token},
+		tokenSourceUrl:   tokenSourceUrl,
+		served:           new(int32),
+		registerAddonUrl: registerAddonUrl,
+	}
 }
 
 // NewAPIToken creates an API instance from a token.
 func NewAPIToken(t string) *API {
 	var token *Token
 
-	if api_url := os.Getenv("CCTRL_API_URL"); api_url != "" {
-		API_URL = api_url
+	apiUrl := API_URL
+	if envUrl := os.Getenv("CCTRL_API_URL"); envUrl != "" {
+		apiUrl = envUrl
 	}
 
 	if t != "" {
 		token = NewToken(t, "")
 	}
 
-	tokenSourceUrl := fmt.Sprintf("%s%s", API_URL, "/token/")
+	tokenSourceUrl := fmt.Sprintf("%s%s", apiUrl, "/token/")
 
-	return &API{CACHE, API_URL, token, tokenSourceUrl, API_URL}
+	return &API{
+		cache:            CACHE,
+		url:              apiUrl,
+		token:            &tokenHolder{token: token},
+		tokenSourceUrl:   tokenSourceUrl,
+		served:           new(int32),
+		registerAddonUrl: apiUrl,
+	}
 }
 
 // Cache returns the API Cache
 func (api *API) Cache() string {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
 	return api.cache
 }
 
 // Url returns the API Url
 func (api *API) Url() string {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
 	return api.url
 }
 
-// SetUrl sets the API Url
-func (api *API) SetUrl(apiUrl string) {
+// SetUrl sets the API Url.
+//
+// Deprecated: pass the URL to NewCustomAPI instead. Returns
+// an error, leaving the URL unchanged, once the API has made
+// a request.
+func (api *API) SetUrl(apiUrl string) error {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if api.hasServed() {
+		return errAPIServed
+	}
 	api.url = apiUrl
+	return nil
+}
+
+// tokenHolder keeps the Token of an API, shared
+// with the copies made by WithContext
+type tokenHolder struct {
+	mu    sync.RWMutex
+	token *Token
+}
+
+// tokens returns the token holder of the API
+func (api *API) tokens() *tokenHolder {
+	api.mu.RLock()
+	holder := api.token
+	api.mu.RUnlock()
+	if holder != nil {
+		return holder
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if api.token == nil {
+		api.token = &tokenHolder{}
+	}
+	return api.token
 }
 
 // Token returns the API Token
 func (api *API) Token() *Token {
-	return api.token
+	holder := api.tokens()
+	holder.mu.RLock()
+	defer holder.mu.RUnlock()
+	return holder.token
 }
 
 // SetToken sets a Token to an API given a string token.
 func (api *API) SetToken(token string, expires string) {
-	api.token = NewToken(token, expires)
+	holder := api.tokens()
+	holder.mu.Lock()
+	defer holder.mu.Unlock()
+	holder.token = NewToken(token, expires)
 }
 
+// ClearToken removes the API Token.
 func (api *API) ClearToken() {
-	api.token = nil
+	holder := api.tokens()
+	holder.mu.Lock()
+	defer holder.mu.Unlock()
+	holder.token = nil
 }
 
 // RequiresToken returns an error if API has no token.
-func (api API) RequiresToken() (e error) {
+func (api *API) RequiresToken() (e error) {
 	if isNil(api.Token()) {
 		e = errors.New("Token required.")
 	}
@@ -182,19 +263,23 @@ func (api API) RequiresToken() (e error) {
 // went wrong.
 // Note: In case of valid token, this method
 // refresh the token expiral date in 15 more minutes.
-func (api API) IsTokenValid() (bool, error) {
+func (api *API) IsTokenValid() (bool, error) {
 	if isNil(api.Token()) {
 		return false, errors.New("Token is not set.")
 	}
 
-	request := NewRequest("", "", &api)
-	if err := request.HeadToken(); err != nil {
+	op := api.begin("IsTokenValid")
+	request := NewRequest("", "", api)
+	request.op = op
+	if err := op.end(request.HeadToken()); err != nil {
 		if err.Error() == "401 UNAUTHORIZED" {
 			return false, nil
 		}
+		api.observeTokenRefresh(err)
 		return false, err
 	}
 
+	api.observeTokenRefresh(nil)
 	return true, nil
 }
 
@@ -205,6 +290,10 @@ func (api API) IsTokenValid() (bool, error) {
 // second for the password. Returns an error if credentials file
 // is not OK or if there were problems creating a token.
 func (api *API) CreateTokenFromFile(filepath string) (err error) {
+	op := api.begin("CreateTokenFromFile")
+	defer func() { op.end(err) }()
+	api = api.within(op)
+
 	email, password, err := readCredentialsFile(filepath)
 	if err != nil {
 		return err
@@ -217,7 +306,11 @@ func (api *API) CreateTokenFromFile(filepath string) (err error) {
 // a email and password.
 // Returns an error if there is any problem creating the token.
 func (api *API) CreateToken(email string, password string) (err error) {
+	op := api.begin("CreateToken")
+	defer func() { api.observeTokenRefresh(op.end(err)) }()
+
 	request := NewRequest(email, password, api)
+	request.op = op
 	content, err := request.PostToken()
 	if err != nil {
 		return err
@@ -234,22 +327,63 @@ func (api *API) CreateToken(email string, password string) (err error) {
 
 // Token returns the API Token Source URL
 func (api *API) TokenSourceUrl() string {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
 	return api.tokenSourceUrl
 }
 
-// Set TokenSourceUrl sets the Token source URL to an api
-func (api *API) SetTokenSourceUrl(tokenSourceUrl string) {
+// Set TokenSourceUrl sets the Token source URL to an api.
+//
+// Deprecated: pass the URL to NewCustomAPI instead. Returns
+// an error, leaving the URL unchanged, once the API has made
+// a request.
+func (api *API) SetTokenSourceUrl(tokenSourceUrl string) error {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if api.hasServed() {
+		return errAPIServed
+	}
 	api.tokenSourceUrl = tokenSourceUrl
+	return nil
 }
 
 // Token returns the API Register Addon URL
 func (api *API) RegisterAddonUrl() string {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
 	return api.registerAddonUrl
 }
 
-// Set RegisterAddonUrl sets the URL for regostering an addon to an api
-func (api *API) SetRegisterAddonUrl(registerAddonUrl string) {
+// Set RegisterAddonUrl sets the URL for regostering an addon to an api.
+//
+// Deprecated: pass the URL to NewCustomAPI instead. Returns
+// an error, leaving the URL unchanged, once the API has made
+// a request.
+func (api *API) SetRegisterAddonUrl(registerAddonUrl string) error {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if api.hasServed() {
+		return errAPIServed
+	}
 	api.registerAddonUrl = registerAddonUrl
+	return nil
+}
+
+// errAPIServed is returned by the URL setters
+// once the API has made a request
+var errAPIServed = errors.New("API URLs can not be changed once the API has made a request.")
+
+// hasServed returns true if the API, or the API it
+// was copied from, has made a request
+func (api *API) hasServed() bool {
+	return api.served != nil && atomic.LoadInt32(api.served) == 1
+}
+
+// serve records that the API makes a request
+func (api *API) serve() {
+	if api.served != nil {
+		atomic.StoreInt32(api.served, 1)
+	}
 }
 
 /*
@@ -278,8 +412,9 @@ func (api *API) CreateApplication(appName, appType, repositoryType, buildpackURL
 		appValues.Add("buildpack_url", buildpackURL)
 	}
 
-	data, err := api.Post("/app/", appValues)
-	return api.decodeApplication(data, err)
+	op := api.begin("CreateApplication")
+	body, err := api.post(op, "/app/", appValues)
+	return api.decodeApplication(op.body(body, err))
 }
 
 // ReadApplications reads applications of current user.
@@ -287,8 +422,9 @@ func (api *API) CreateApplication(appName, appType, repositoryType, buildpackURL
 // Returns a list of Applications
 // and an error if request does not success.
 func (api *API) ReadApplications() (*[]Application, error) {
-	data, err := api.Get("/app/")
-	return api.decodeApplications(data, err)
+	op := api.begin("ReadApplications")
+	body, err := api.get(op, "/app/")
+	return api.decodeApplications(op.body(body, err))
 }
 
 // ReadApplication reads an application having:
@@ -298,8 +434,9 @@ func (api *API) ReadApplications() (*[]Application, error) {
 // Returns an Application and
 // an error if request does not success.
 func (api *API) ReadApplication(appName string) (*Application, error) {
-	data, err := api.Get(fmt.Sprintf("/app/%s/", appName))
-	return api.decodeApplication(data, err)
+	op := api.begin("ReadApplication")
+	body, err := api.get(op, fmt.Sprintf("/app/%s/", appName))
+	return api.decodeApplication(op.body(body, err))
 }
 
 // DeleteApplication deletes an application having:
@@ -308,7 +445,8 @@ func (api *API) ReadApplication(appName string) (*Application, error) {
 //
 // Returns an error if request does not success.
 func (api *API) DeleteApplication(appName string) error {
-	return api.Delete(fmt.Sprintf("/app/%s/", appName))
+	op := api.begin("DeleteApplication")
+	return discardBody(op.body(api.delete(op, fmt.Sprintf("/app/%s/", appName))))
 }
 
 /*
@@ -335,8 +473,9 @@ func (api *API) CreateDeployment(appName, depName, stack string) (*Deployment, e
 		dep.Add("stack", stack)
 	}
 
-	data, err := api.Post(fmt.Sprintf("/app/%s/deployment/", appName), dep)
-	return api.decodeDeployment(data, err)
+	op := api.begin("CreateDeployment")
+	body, err := api.post(op, fmt.Sprintf("/app/%s/deployment/", appName), dep)
+	return api.decodeDeployment(op.body(body, err))
 }
 
 // ReadDeployment reads a deployment having:
@@ -348,8 +487,9 @@ func (api *API) CreateDeployment(appName, depName, stack string) (*Deployment, e
 // Returns a Deployment and
 // an error if request does not success.
 func (api *API) ReadDeployment(appName, depName string) (*Deployment, error) {
-	data, err := api.Get(fmt.Sprintf("/app/%s/deployment/%s/", appName, depName))
-	return api.decodeDeployment(data, err)
+	op := api.begin("ReadDeployment")
+	body, err := api.get(op, fmt.Sprintf("/app/%s/deployment/%s/", appName, depName))
+	return api.decodeDeployment(op.body(body, err))
 }
 
 // ReadDeployments reads all user deployments having:
@@ -361,8 +501,9 @@ func (api *API) ReadDeployment(appName, depName string) (*Deployment, error) {
 // Returns a Deployment and
 // an error if request does not success.
 func (api *API) ReadDeployments(appName string) (*[]Deployment, error) {
-	data, err := api.Get(fmt.Sprintf("/app/%s/deployment/", appName))
-	return api.decodeDeployments(data, err)
+	op := api.begin("ReadDeployments")
+	body, err := api.get(op, fmt.Sprintf("/app/%s/deployment/", appName))
+	return api.decodeDeployments(op.body(body, err))
 }
 
 // UpdateDeployment updates deployment having:
@@ -408,8 +549,51 @@ func (api *API) UpdateDeployment(appName, depName, version, billingAccount, stac
 		dep.Add("stack", stack)
 	}
 
-	data, err := api.Put(fmt.Sprintf("/app/%s/deployment/%s/", appName, depName), dep)
-	return api.decodeDeployment(data, err)
+	op := api.begin("UpdateDeployment")
+	deployment, err := api.updateDeployment(op, appName, depName, dep, false)
+	return deployment, op.end(err)
+}
+
+// updateDeployment updates a deployment and, if a version is
+// pushed, records it in the deploy journal, if any, together
+// with the version it replaces when it was deployed. The API
+// notifier, if any, is notified of the update in the background.
+// Returns the updated Deployment and an error if the version
+// was pushed but the journal could not be written.
+func (api *API) updateDeployment(op *operation, appName, depName string, dep url.Values, rollback bool) (*Deployment, error) {
+	deployment, err := api.journalDeployment(op, appName, depName, dep, rollback)
+	if deployment != nil && api.Plan() == nil {
+		api.notifyDeployment(appName, depName, dep, rollback)
+	}
+	return deployment, err
+}
+
+func (api *API) journalDeployment(op *operation, appName, depName string, dep url.Values, rollback bool) (*Deployment, error) {
+	journal := api.DeployJournal()
+	version := dep.Get("version")
+	if journal == nil || version == "" || api.Plan() != nil {
+		body, err := api.put(op, fmt.Sprintf("/app/%s/deployment/%s/", appName, depName), dep)
+		return api.decodeDeployment(body, err)
+	}
+
+	current, err := api.within(op).ReadDeployment(appName, depName)
+	if err != nil {
+		return nil, err
+	}
+	if current.State == DeployActive && current.Version != "" {
+		if err = journal.Append(DeployRecord{App: appName, Deployment: depName, Version: current.Version, State: DeployActive}); err != nil {
+			return nil, err
+		}
+	}
+
+	body, err := api.put(op, fmt.Sprintf("/app/%s/deployment/%s/", appName, depName), dep)
+	deployment, err := api.decodeDeployment(body, err)
+	if err != nil {
+		return nil, err
+	}
+
+	err = journal.Append(DeployRecord{App: appName, Deployment: depName, Version: version, State: DeployPushed, Rollback: rollback})
+	return deployment, err
 }
 
 // DeleteDeployment deletes a deployment having:
@@ -420,7 +604,8 @@ func (api *API) UpdateDeployment(appName, depName, version, billingAccount, stac
 //
 // Returns an error if request does not success.
 func (api *API) DeleteDeployment(appName, depName string) error {
-	return api.Delete(fmt.Sprintf("/app/%s/deployment/%s/", appName, depName))
+	op := api.begin("DeleteDeployment")
+	return discardBody(op.body(api.delete(op, fmt.Sprintf("/app/%s/deployment/%s/", appName, depName))))
 }
 
 /*
@@ -442,8 +627,9 @@ func (api *API) CreateAlias(appName, aliasName, depName string) (*Alias, error)
 	aliasValues := url.Values{}
 	aliasValues.Add("name", aliasName)
 
-	data, err := api.Post(fmt.Sprintf("/app/%s/deployment/%s/alias/", appName, depName), aliasValues)
-	return api.decodeAlias(data, err)
+	op := api.begin("CreateAlias")
+	body, err := api.post(op, fmt.Sprintf("/app/%s/deployment/%s/alias/", appName, depName), aliasValues)
+	return api.decodeAlias(op.body(body, err))
 }
 
 // ReadAliases reads all deployment's aliases having:
@@ -455,8 +641,9 @@ func (api *API) CreateAlias(appName, aliasName, depName string) (*Alias, error)
 // Returns an interface with aliases details
 // and an error if request does not success.
 func (api *API) ReadAliases(appName, depName string) (*[]Alias, error) {
-	data, err := api.Get(fmt.Sprintf("/app/%s/deployment/%s/alias/", appName, depName))
-	return api.decodeAliases(data, err)
+	op := api.begin("ReadAliases")
+	body, err := api.get(op, fmt.Sprintf("/app/%s/deployment/%s/alias/", appName, depName))
+	return api.decodeAliases(op.body(body, err))
 }
 
 // ReadAlias reads a deployment's alias having:
@@ -470,8 +657,9 @@ func (api *API) ReadAliases(appName, depName string) (*[]Alias, error) {
 // Returns an Alias
 // and an error if request does not success.
 func (api *API) ReadAlias(appName, aliasName, depName string) (*Alias, error) {
-	data, err := api.Get(fmt.Sprintf("/app/%s/deployment/%s/alias/%s/", appName, depName, aliasName))
-	return api.decodeAlias(data, err)
+	op := api.begin("ReadAlias")
+	body, err := api.get(op, fmt.Sprintf("/app/%s/deployment/%s/alias/%s/", appName, depName, aliasName))
+	return api.decodeAlias(op.body(body, err))
 
 }
 
@@ -485,7 +673,8 @@ func (api *API) ReadAlias(appName, aliasName, depName string) (*Alias, error) {
 //
 // Returns an error if request does not success.
 func (api *API) DeleteAlias(appName, aliasName, depName string) error {
-	return api.Delete(fmt.Sprintf("/app/%s/deployment/%s/alias/%s/", appName, depName, aliasName))
+	op := api.begin("DeleteAlias")
+	return discardBody(op.body(api.delete(op, fmt.Sprintf("/app/%s/deployment/%s/alias/%s/", appName, depName, aliasName))))
 }
 
 /*
@@ -518,8 +707,9 @@ func (api *API) CreateWorker(appName, depName, command, params, size string) (*W
 		workerValues.Add("size", size)
 	}
 
-	data, err := api.Post(fmt.Sprintf("/app/%s/deployment/%s/worker/", appName, depName), workerValues)
-	return api.decodeWorker(data, err)
+	op := api.begin("CreateWorker")
+	body, err := api.post(op, fmt.Sprintf("/app/%s/deployment/%s/worker/", appName, depName), workerValues)
+	return api.decodeWorker(op.body(body, err))
 }
 
 // ReadWorkers reads all deployment's workers having:
@@ -531,8 +721,9 @@ func (api *API) CreateWorker(appName, depName, command, params, size string) (*W
 // Returns a list of workers
 // and an error if request does not success.
 func (api *API) ReadWorkers(appName, depName string) (*[]Worker, error) {
-	data, err := api.Get(fmt.Sprintf("/app/%s/deployment/%s/worker/", appName, depName))
-	return api.decodeWorkers(data, err)
+	op := api.begin("ReadWorkers")
+	body, err := api.get(op, fmt.Sprintf("/app/%s/deployment/%s/worker/", appName, depName))
+	return api.decodeWorkers(op.body(body, err))
 }
 
 // ReadWorker reads a deployment's worker having:
@@ -546,8 +737,9 @@ func (api *API) ReadWorkers(appName, depName string) (*[]Worker, error) {
 // Returns a Worker
 // and an error if request does not success.
 func (api *API) ReadWorker(appName, depName, workerId string) (*Worker, error) {
-	data, err := api.Get(fmt.Sprintf("/app/%s/deployment/%s/worker/%s/", appName, depName, workerId))
-	return api.decodeWorker(data, err)
+	op := api.begin("ReadWorker")
+	body, err := api.get(op, fmt.Sprintf("/app/%s/deployment/%s/worker/%s/", appName, depName, workerId))
+	return api.decodeWorker(op.body(body, err))
 }
 
 // DeleteWorker removes a worker from a deployment having:
@@ -560,7 +752,8 @@ func (api *API) ReadWorker(appName, depName, workerId string) (*Worker, error) {
 //
 // Returns an error if request does not success.
 func (api *API) DeleteWorker(appName, depName, workerId string) error {
-	return api.Delete(fmt.Sprintf("/app/%s/deployment/%s/worker/%s/", appName, depName, workerId))
+	op := api.begin("DeleteWorker")
+	return discardBody(op.body(api.delete(op, fmt.Sprintf("/app/%s/deployment/%s/worker/%s/", appName, depName, workerId))))
 }
 
 /*
@@ -577,12 +770,39 @@ func (api *API) DeleteWorker(appName, depName, workerId string) error {
 //
 // Returns the just created Cronjob
 // and an error if request does not success.
-func (api *API) CreateCronjob(appName, depName, urlJob string) (*Cronjob, error) {
+func (api *API) CreateCronjob(appName, depName, urlJob string) (_ *Cronjob, err error) {
+	op := api.begin("CreateCronjob")
+	defer func() { op.end(err) }()
+	api = api.within(op)
+
+	return api.CreateCronjobSpec(appName, depName, CronjobSpec{URL: urlJob})
+}
+
+// CreateCronjobSpec adds a cronjob to a deployment having:
+//
+// * Application name
+//
+// * Deployment name
+//
+// * Cronjob spec with the URL and, optionally, the interval
+//
+// Returns the just created Cronjob
+// and an error if request does not success.
+func (api *API) CreateCronjobSpec(appName, depName string, spec CronjobSpec) (*Cronjob, error) {
+	if err := spec.Validate(); err != nil {
+		return nil, err
+	}
+
 	cronjobValues := url.Values{}
-	cronjobValues.Add("url", urlJob)
+	cronjobValues.Add("url", spec.URL)
 
-	data, err := api.Post(fmt.Sprintf("/app/%s/deployment/%s/cron/", appName, depName), cronjobValues)
-	return api.decodeCronjob(data, err)
+	if spec.Interval != "" {
+		cronjobValues.Add("interval", spec.Interval)
+	}
+
+	op := api.begin("CreateCronjobSpec")
+	body, err := api.post(op, fmt.Sprintf("/app/%s/deployment/%s/cron/", appName, depName), cronjobValues)
+	return api.decodeCronjob(op.body(body, err))
 }
 
 // ReadCronjobs reads all deployment's cronjobs having:
@@ -594,8 +814,9 @@ func (api *API) CreateCronjob(appName, depName, urlJob string) (*Cronjob, error)
 // Returns a Cronjob
 // and an error if request does not success.
 func (api *API) ReadCronjobs(appName, depName string) (*[]Cronjob, error) {
-	data, err := api.Get(fmt.Sprintf("/app/%s/deployment/%s/cron/", appName, depName))
-	return api.decodeCronjobs(data, err)
+	op := api.begin("ReadCronjobs")
+	body, err := api.get(op, fmt.Sprintf("/app/%s/deployment/%s/cron/", appName, depName))
+	return api.decodeCronjobs(op.body(body, err))
 }
 
 // ReadCronjob reads a deployment's cronjob having:
@@ -609,8 +830,9 @@ func (api *API) ReadCronjobs(appName, depName string) (*[]Cronjob, error) {
 // Returns a Cronjob
 // and an error if request does not success.
 func (api *API) ReadCronjob(appName, depName, cronjobId string) (*Cronjob, error) {
-	data, err := api.Get(fmt.Sprintf("/app/%s/deployment/%s/cron/%s/", appName, depName, cronjobId))
-	return api.decodeCronjob(data, err)
+	op := api.begin("ReadCronjob")
+	body, err := api.get(op, fmt.Sprintf("/app/%s/deployment/%s/cron/%s/", appName, depName, cronjobId))
+	return api.decodeCronjob(op.body(body, err))
 }
 
 // DeleteCronjob removes a cronjob from a deployment having:
@@ -623,7 +845,8 @@ func (api *API) ReadCronjob(appName, depName, cronjobId string) (*Cronjob, error
 //
 // Returns an error if request does not success.
 func (api *API) DeleteCronjob(appName, depName, cronjobId string) error {
-	return api.Delete(fmt.Sprintf("/app/%s/deployment/%s/cron/%s/", appName, depName, cronjobId))
+	op := api.begin("DeleteCronjob")
+	return discardBody(op.body(api.delete(op, fmt.Sprintf("/app/%s/deployment/%s/cron/%s/", appName, depName, cronjobId))))
 }
 
 /*
@@ -641,9 +864,40 @@ func (api *API) DeleteCronjob(appName, depName, cronjobId string) error {
 // Returns the just registered Addon
 // and an error if request does not success.
 func (api *API) RegisterAddon(email string, password string, data []byte) (*Addon, error) {
+	op := api.begin("RegisterAddon")
 	request := NewRequest(email, password, api)
-	data, err := request.PostAddon(data)
-	return api.decodeAddon(data, err)
+	request.op = op
+	body, err := request.stream("", "POST", data, false, true)
+	return api.decodeAddon(op.body(body, err))
+}
+
+// RegisterAddonManifest registers a new addon into the platform having:
+//
+// * Email of the addon owner
+//
+// * Password of the addon owner
+//
+// * Addon manifest
+//
+// The manifest is validated before being sent.
+//
+// Returns the just registered Addon
+// and an error if manifest is not valid or request does not success.
+func (api *API) RegisterAddonManifest(email string, password string, manifest *AddonManifest) (_ *Addon, err error) {
+	op := api.begin("RegisterAddonManifest")
+	defer func() { op.end(err) }()
+	api = api.within(op)
+
+	if err := manifest.Validate(); err != nil {
+		return nil, err
+	}
+
+	data, err := manifest.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	return api.RegisterAddon(email, password, data)
 }
 
 // CreateAddon creates an addon having:
@@ -676,8 +930,9 @@ func (api *API) CreateAddon(appName, depName, addonName string, settings *Settin
 
 	addonValues.Add("options", string(o))
 
-	data, err := api.Post(fmt.Sprintf("/app/%s/deployment/%s/addon/", appName, depName), addonValues)
-	return api.decodeAddon(data, err)
+	op := api.begin("CreateAddon")
+	body, err := api.post(op, fmt.Sprintf("/app/%s/deployment/%s/addon/", appName, depName), addonValues)
+	return api.decodeAddon(op.body(body, err))
 }
 
 // ReadCronjobs reads all deployment's addons having:
@@ -691,16 +946,17 @@ func (api *API) CreateAddon(appName, depName, addonName string, settings *Settin
 // Otherwise it returns deployment's Addons
 // and an error if request does not success.
 func (api *API) ReadAddons(appName, depName string) (*[]Addon, error) {
-	var data interface{}
+	var body io.ReadCloser
 	var err error
 
+	op := api.begin("ReadAddons")
 	if appName != "" && depName != "" {
-		data, err = api.Get(fmt.Sprintf("/app/%s/deployment/%s/addon/", appName, depName))
+		body, err = api.get(op, fmt.Sprintf("/app/%s/deployment/%s/addon/", appName, depName))
 	} else {
-		data, err = api.Get("/addon/")
+		body, err = api.get(op, "/addon/")
 	}
 
-	return api.decodeAddons(data, err)
+	return api.decodeAddons(op.body(body, err))
 }
 
 // ReadAddon reads a deployment's addon having:
@@ -714,8 +970,9 @@ func (api *API) ReadAddons(appName, depName string) (*[]Addon, error) {
 // Returns an interface with addon details
 // and an error if request does not success.
 func (api *API) ReadAddon(appName, depName, addonName string) (*Addon, error) {
-	data, err := api.Get(fmt.Sprintf("/app/%s/deployment/%s/addon/%s/", appName, depName, addonName))
-	return api.decodeAddon(data, err)
+	op := api.begin("ReadAddon")
+	body, err := api.get(op, fmt.Sprintf("/app/%s/deployment/%s/addon/%s/", appName, depName, addonName))
+	return api.decodeAddon(op.body(body, err))
 }
 
 // UpdateAddon updates addon having:
@@ -761,8 +1018,9 @@ func (api *API) UpdateAddon(appName, depName, addonName, addonNameToUpdateTo str
 		addonValues.Add("force", "true")
 	}
 
-	data, err := api.Put(fmt.Sprintf("/app/%s/deployment/%s/addon/%s/", appName, depName, addonName), addonValues)
-	return api.decodeAddon(data, err)
+	op := api.begin("UpdateAddon")
+	body, err := api.put(op, fmt.Sprintf("/app/%s/deployment/%s/addon/%s/", appName, depName, addonName), addonValues)
+	return api.decodeAddon(op.body(body, err))
 }
 
 // DeleteAddon deletes an addon having:
@@ -775,7 +1033,8 @@ func (api *API) UpdateAddon(appName, depName, addonName, addonNameToUpdateTo str
 //
 // Returns an error if request does not success.
 func (api *API) DeleteAddon(appName, depName, addonName string) error {
-	return api.Delete(fmt.Sprintf("/app/%s/deployment/%s/addon/%s/", appName, depName, addonName))
+	op := api.begin("DeleteAddon")
+	return discardBody(op.body(api.delete(op, fmt.Sprintf("/app/%s/deployment/%s/addon/%s/", appName, depName, addonName))))
 }
 
 /*
@@ -800,8 +1059,9 @@ func (api *API) CreateAppUser(appName, userEmail, role string) (*User, error) {
 		userValues.Add("role", role)
 	}
 
-	data, err := api.Post(fmt.Sprintf("/app/%s/user/", appName), userValues)
-	return api.decodeUser(data, err)
+	op := api.begin("CreateAppUser")
+	body, err := api.post(op, fmt.Sprintf("/app/%s/user/", appName), userValues)
+	return api.decodeUser(op.body(body, err))
 }
 
 // ReadAppUsers reads all application's users having:
@@ -811,8 +1071,9 @@ func (api *API) CreateAppUser(appName, userEmail, role string) (*User, error) {
 // Returns a list of application Users
 // and an error if request does not success.
 func (api *API) ReadAppUsers(appName string) (*[]User, error) {
-	data, err := api.Get(fmt.Sprintf("/app/%s/user/", appName))
-	return api.decodeUsers(data, err)
+	op := api.begin("ReadAppUsers")
+	body, err := api.get(op, fmt.Sprintf("/app/%s/user/", appName))
+	return api.decodeUsers(op.body(body, err))
 }
 
 // DeleteAppUser removes an user from an application having:
@@ -823,7 +1084,8 @@ func (api *API) ReadAppUsers(appName string) (*[]User, error) {
 //
 // Returns an error if request does not success.
 func (api *API) DeleteAppUser(appName, userName string) error {
-	return api.Delete(fmt.Sprintf("/app/%s/user/%s/", appName, userName))
+	op := api.begin("DeleteAppUser")
+	return discardBody(op.body(api.delete(op, fmt.Sprintf("/app/%s/user/%s/", appName, userName))))
 }
 
 /*
@@ -850,8 +1112,9 @@ func (api *API) CreateDeploymentUser(appName, depName, userEmail, role string) (
 		userValues.Add("role", role)
 	}
 
-	data, err := api.Post(fmt.Sprintf("/app/%s/deployment/%s/user/", appName, depName), userValues)
-	return api.decodeUser(data, err)
+	op := api.begin("CreateDeploymentUser")
+	body, err := api.post(op, fmt.Sprintf("/app/%s/deployment/%s/user/", appName, depName), userValues)
+	return api.decodeUser(op.body(body, err))
 }
 
 // ReadDeploymentUsers reads deployment's users having:
@@ -863,8 +1126,9 @@ func (api *API) CreateDeploymentUser(appName, depName, userEmail, role string) (
 // Returns an interface with deployment's users details
 // and an error if request does not success.
 func (api *API) ReadDeploymentUsers(appName, depName string) (*[]User, error) {
-	data, err := api.Get(fmt.Sprintf("/app/%s/deployment/%s/user/", appName, depName))
-	return api.decodeUsers(data, err)
+	op := api.begin("ReadDeploymentUsers")
+	body, err := api.get(op, fmt.Sprintf("/app/%s/deployment/%s/user/", appName, depName))
+	return api.decodeUsers(op.body(body, err))
 }
 
 // DeleteDeploymentUser removes an user from a deployment having:
@@ -877,14 +1141,15 @@ func (api *API) ReadDeploymentUsers(appName, depName string) (*[]User, error) {
 //
 // Returns an error if request does not success.
 func (api *API) DeleteDeploymentUser(appName, depName, userName string) error {
-	return api.Delete(fmt.Sprintf("/app/%s/deployment/%s/user/%s/", appName, depName, userName))
+	op := api.begin("DeleteDeploymentUser")
+	return discardBody(op.body(api.delete(op, fmt.Sprintf("/app/%s/deployment/%s/user/%s/", appName, depName, userName))))
 }
 
 /*
 	Users
 */
 
-// CreateUser creates a new user having:
+// CreateUser registers a new user having:
 //
 // * User name
 //
@@ -892,6 +1157,8 @@ func (api *API) DeleteDeploymentUser(appName, depName, userName string) error {
 //
 // * User password
 //
+// It does not require a token.
+//
 // Returns the just created User
 // and an error if request does not success.
 func (api *API) CreateUser(userName, userEmail, password string) (*User, error) {
@@ -900,8 +1167,9 @@ func (api *API) CreateUser(userName, userEmail, password string) (*User, error)
 	userValues.Add("email", userEmail)
 	userValues.Add("password", password)
 
-	data, err := api.Post("/user/", userValues)
-	return api.decodeUser(data, err)
+	op := api.begin("CreateUser")
+	body, err := api.postAnonymous(op, "/user/", userValues)
+	return api.decodeUser(op.body(body, err))
 }
 
 // ReadUsers gets users. Usually just your own.
@@ -909,8 +1177,9 @@ func (api *API) CreateUser(userName, userEmail, password string) (*User, error)
 // Returns a list of Users
 // and an error if request does not success.
 func (api *API) ReadUsers() (*[]User, error) {
-	data, err := api.Get("/user/")
-	return api.decodeUsers(data, err)
+	op := api.begin("ReadUsers")
+	body, err := api.get(op, "/user/")
+	return api.decodeUsers(op.body(body, err))
 }
 
 // ReadUser reads a user having:
@@ -920,8 +1189,9 @@ func (api *API) ReadUsers() (*[]User, error) {
 // Returns a User
 // and an error if request does not success.
 func (api *API) ReadUser(userName string) (*User, error) {
-	data, err := api.Get(fmt.Sprintf("/user/%s/", userName))
-	return api.decodeUser(data, err)
+	op := api.begin("ReadUser")
+	body, err := api.get(op, fmt.Sprintf("/user/%s/", userName))
+	return api.decodeUser(op.body(body, err))
 }
 
 // ActivateUser activates a new user having:
@@ -930,6 +1200,8 @@ func (api *API) ReadUser(userName string) (*User, error) {
 //
 // * Activation code for activation after registration
 //
+// It does not require a token.
+//
 // Returns the activated User
 // and an error if request does not success.
 func (api *API) ActivateUser(userName, activationCode string) (*User, error) {
@@ -938,8 +1210,60 @@ func (api *API) ActivateUser(userName, activationCode string) (*User, error) {
 		userValues.Add("activation_code", activationCode)
 	}
 
-	data, err := api.Put(fmt.Sprintf("/user/%s/", userName), userValues)
-	return api.decodeUser(data, err)
+	op := api.begin("ActivateUser")
+	body, err := api.putAnonymous(op, fmt.Sprintf("/user/%s/", userName), userValues)
+	return api.decodeUser(op.body(body, err))
+}
+
+// ResendActivation sends the activation code again having:
+//
+// * User email
+//
+// It does not require a token.
+//
+// Returns an error if request does not success.
+func (api *API) ResendActivation(userEmail string) error {
+	userValues := url.Values{}
+	userValues.Add("email", userEmail)
+
+	op := api.begin("ResendActivation")
+	return discardBody(op.body(api.postAnonymous(op, "/user/activation/", userValues)))
+}
+
+// RequestPasswordReset sends a password reset code by email having:
+//
+// * User email
+//
+// It does not require a token.
+//
+// Returns an error if request does not success.
+func (api *API) RequestPasswordReset(userEmail string) error {
+	userValues := url.Values{}
+	userValues.Add("email", userEmail)
+
+	op := api.begin("RequestPasswordReset")
+	return discardBody(op.body(api.postAnonymous(op, "/user/password_reset/", userValues)))
+}
+
+// ResetPassword sets a new password having:
+//
+// * User email
+//
+// * Reset code provided by email
+//
+// * New password
+//
+// It does not require a token.
+//
+// Returns an error if request does not success.
+func (api *API) ResetPassword(userEmail, resetCode, password string) error {
+	userValues := url.Values{}
+	userValues.Add("email", userEmail)
+	userValues.Add("reset_code", resetCode)
+	userValues.Add("password", password)
+
+	op := api.begin("ResetPassword")
+	return discardBody(op.body(api.putAnonymous(op, "/user/password_reset/", userValues)))
 }
 
 // UpdateUser updates an existing user having:
@@ -973,18 +1297,20 @@ func (api *API) UpdateUser(userName, firstName, lastName, password, email string
 		userValues.Add("email", email)
 	}
 
-	data, err := api.Put(fmt.Sprintf("/user/%s/", userName), userValues)
-	return api.decodeUser(data, err)
+	op := api.begin("UpdateUser")
+	body, err := api.put(op, fmt.Sprintf("/user/%s/", userName), userValues)
+	return api.decodeUser(op.body(body, err))
 
 }
 
-// DeleteUser deletes as user having:
+// DeleteUser deletes a user having:
 //
 // * User name
 //
 // Returns an error if request does not success.
 func (api *API) DeleteUser(userName string) error {
-	return api.Delete(fmt.Sprintf("/app/%s/", userName))
+	op := api.begin("DeleteUser")
+	return discardBody(op.body(api.delete(op, fmt.Sprintf("/user/%s/", userName))))
 }
 
 /*
@@ -1003,8 +1329,9 @@ func (api *API) CreateUserKey(userName, publicKey string) (*Key, error) {
 	keyValues := url.Values{}
 	keyValues.Add("key", publicKey)
 
-	data, err := api.Post(fmt.Sprintf("/user/%s/key/", userName), keyValues)
-	return api.decodeKey(data, err)
+	op := api.begin("CreateUserKey")
+	body, err := api.post(op, fmt.Sprintf("/user/%s/key/", userName), keyValues)
+	return api.decodeKey(op.body(body, err))
 }
 
 // ReadUserKeys gets all user keys having:
@@ -1014,8 +1341,9 @@ func (api *API) CreateUserKey(userName, publicKey string) (*Key, error) {
 // Returns a list of Keys
 // and an error if request does not success.
 func (api *API) ReadUserKeys(userName string) (*[]Key, error) {
-	data, err := api.Get(fmt.Sprintf("/user/%s/key/", userName))
-	return api.decodeKeys(data, err)
+	op := api.begin("ReadUserKeys")
+	body, err := api.get(op, fmt.Sprintf("/user/%s/key/", userName))
+	return api.decodeKeys(op.body(body, err))
 }
 
 // ReadUserKey reads a user's key having:
@@ -1027,8 +1355,9 @@ func (api *API) ReadUserKeys(userName string) (*[]Key, error) {
 // Returns the Key
 // and an error if request does not success.
 func (api *API) ReadUserKey(userName, keyId string) (*Key, error) {
-	data, err := api.Get(fmt.Sprintf("/user/%s/key/%s/", userName, keyId))
-	return api.decodeKey(data, err)
+	op := api.begin("ReadUserKey")
+	body, err := api.get(op, fmt.Sprintf("/user/%s/key/%s/", userName, keyId))
+	return api.decodeKey(op.body(body, err))
 }
 
 // DeleteUserKey deletes a user's key having:
@@ -1039,7 +1368,8 @@ func (api *API) ReadUserKey(userName, keyId string) (*Key, error) {
 //
 // Returns an error if request does not success.
 func (api *API) DeleteUserKey(userName, keyID string) error {
-	return api.Delete(fmt.Sprintf("/user/%s/key/%s/", userName, keyID))
+	op := api.begin("DeleteUserKey")
+	return discardBody(op.body(api.delete(op, fmt.Sprintf("/user/%s/key/%s/", userName, keyID))))
 }
 
 /*
@@ -1064,11 +1394,12 @@ func (api *API) ReadLog(appName, depName, logType string, lastTime *time.Time) (
 	if lastTime == nil {
 		resource = fmt.Sprintf("/app/%s/deployment/%s/log/%s/", appName, depName, logType)
 	} else {
-		resource = fmt.Sprintf("/app/%s/deployment/%s/log/%s/?timestamp=%s/", appName, depName, logType, buildTimestamp(lastTime))
+		resource = fmt.Sprintf("/app/%s/deployment/%s/log/%s/?timestamp=%s", appName, depName, logType, buildTimestamp(lastTime))
 	}
 
-	data, err := api.Get(resource)
-	return api.decodeLogs(data, err)
+	op := api.begin("ReadLog")
+	body, err := api.get(op, resource)
+	return api.decodeLogs(op.body(body, err))
 }
 
 /*
@@ -1086,8 +1417,9 @@ func (api *API) ReadLog(appName, depName, logType string, lastTime *time.Time) (
 // Returns just created BillingAccount
 // and an error if request does not success.
 func (api *API) CreateBillingAccount(userName, billingName string, billingData url.Values) (*BillingAccount, error) {
-	data, err := api.Post(fmt.Sprintf("/user/%s/billing/%s/", userName, billingName), billingData)
-	return api.decodeBillingAccount(data, err)
+	op := api.begin("CreateBillingAccount")
+	body, err := api.post(op, fmt.Sprintf("/user/%s/billing/%s/", userName, billingName), billingData)
+	return api.decodeBillingAccount(op.body(body, err))
 
 }
 
@@ -1098,8 +1430,9 @@ func (api *API) CreateBillingAccount(userName, billingName string, billingData u
 // Returns a list of user's BillingAccounts
 // and an error if request does not success.
 func (api *API) ReadBillingAccounts(userName string) (*[]BillingAccount, error) {
-	data, err := api.Get(fmt.Sprintf("/user/%s/billing/", userName))
-	return api.decodeBillingAccounts(data, err)
+	op := api.begin("ReadBillingAccounts")
+	body, err := api.get(op, fmt.Sprintf("/user/%s/billing/", userName))
+	return api.decodeBillingAccounts(op.body(body, err))
 }
 
 // UpdateBillingAccount updates an existing user's billing account having:
@@ -1113,8 +1446,9 @@ func (api *API) ReadBillingAccounts(userName string) (*[]BillingAccount, error)
 // Returns updated user's BillingAccount
 // and an error if request does not success.
 func (api *API) UpdateBillingAccount(userName, billingName string, billingData url.Values) (*BillingAccount, error) {
-	data, err := api.Put(fmt.Sprintf("/user/%s/billing/%s/", userName, billingName), billingData)
-	return api.decodeBillingAccount(data, err)
+	op := api.begin("UpdateBillingAccount")
+	body, err := api.put(op, fmt.Sprintf("/user/%s/billing/%s/", userName, billingName), billingData)
+	return api.decodeBillingAccount(op.body(body, err))
 }
 
 /*
@@ -1126,18 +1460,9 @@ func (api *API) UpdateBillingAccount(userName, billingName string, billingData u
 // Returns an interface with the requested object
 // and an error if request does not success.
 func (api *API) Get(resource string) (interface{}, error) {
-	if err := api.RequiresToken(); err != nil {
-		return nil, err
-	}
-
-	request := NewRequest("", "", api)
-
-	content, err := request.Get(resource)
-	if err != nil {
-		return nil, err
-	}
-
-	return decodeContent(content)
+	op := api.begin("Get")
+	body, err := api.get(op, resource)
+	return api.decodeInterface(op.body(body, err))
 }
 
 // Post makes a POST request having a resource and data.
@@ -1145,18 +1470,9 @@ func (api *API) Get(resource string) (interface{}, error) {
 // Returns an interface with the new object
 // and an error if request does not success.
 func (api *API) Post(resource string, data url.Values) (interface{}, error) {
-	if err := api.RequiresToken(); err != nil {
-		return nil, err
-	}
-
-	request := NewRequest("", "", api)
-
-	content, err := request.Post(resource, data)
-	if err != nil {
-		return nil, err
-	}
-
-	return decodeContent(content)
+	op := api.begin("Post")
+	body, err := api.post(op, resource, data)
+	return api.decodeInterface(op.body(body, err))
 }
 
 // Put makes a PUT request having a resource and data.
@@ -1164,297 +1480,344 @@ func (api *API) Post(resource string, data url.Values) (interface{}, error) {
 // Returns an interface with the updated object
 // and an error if request does not success.
 func (api *API) Put(resource string, data url.Values) (interface{}, error) {
-	if err := api.RequiresToken(); err != nil {
-		return nil, err
-	}
-
-	request := NewRequest("", "", api)
-
-	content, err := request.Put(resource, data)
-	if err != nil {
-		return nil, err
-	}
-
-	return decodeContent(content)
+	op := api.begin("Put")
+	body, err := api.put(op, resource, data)
+	return api.decodeInterface(op.body(body, err))
 }
 
 // Delete makes a DELETE request having a resource.
 //
 // Returns an error if request does not success.
 func (api *API) Delete(resource string) error {
+	op := api.begin("Delete")
+	return discardBody(op.body(api.delete(op, resource)))
+}
+
+// get makes a GET request of the operation op
+// and returns the response body.
+func (api *API) get(op *operation, resource string) (io.ReadCloser, error) {
+	return api.stream(op, resource, "GET", []byte{})
+}
+
+// post makes a POST request of the operation op
+// and returns the response body.
+func (api *API) post(op *operation, resource string, data url.Values) (io.ReadCloser, error) {
+	return api.stream(op, resource, "POST", []byte(data.Encode()))
+}
+
+// put makes a PUT request of the operation op
+// and returns the response body.
+func (api *API) put(op *operation, resource string, data url.Values) (io.ReadCloser, error) {
+	return api.stream(op, resource, "PUT", []byte(data.Encode()))
+}
+
+// delete makes a DELETE request of the operation op
+// and returns the response body.
+func (api *API) delete(op *operation, resource string) (io.ReadCloser, error) {
+	return api.stream(op, resource, "DELETE", []byte{})
+}
+
+// stream makes a request of the operation op, if the
+// API has a token, and returns the response body.
+func (api *API) stream(op *operation, resource string, method string, data []byte) (io.ReadCloser, error) {
 	if err := api.RequiresToken(); err != nil {
-		return err
+		return nil, err
 	}
 
 	request := NewRequest("", "", api)
+	request.op = op
+	return request.stream(resource, method, data, false, false)
+}
 
-	content, err := request.Delete(resource)
-	if err != nil {
-		return err
-	}
+// postAnonymous makes a POST request of the operation op
+// without credentials and returns the response body.
+func (api *API) postAnonymous(op *operation, resource string, data url.Values) (io.ReadCloser, error) {
+	request := NewRequest("", "", api)
+	request.anonymous = true
+	request.op = op
+	return request.stream(resource, "POST", []byte(data.Encode()), false, false)
+}
 
-	_, err = decodeContent(content)
-	return err
+// putAnonymous makes a PUT request of the operation op
+// without credentials and returns the response body.
+func (api *API) putAnonymous(op *operation, resource string, data url.Values) (io.ReadCloser, error) {
+	request := NewRequest("", "", api)
+	request.anonymous = true
+	request.op = op
+	return request.stream(resource, "PUT", []byte(data.Encode()), false, false)
 }
 
 /*
 	Type decoders
 */
 
-func (api *API) decodeApplication(data interface{}, err error) (*Application, error) {
+func (api *API) decodeInterface(body io.ReadCloser, err error) (interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	var data interface{}
+	if err = decodeBody(body, &data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+func (api *API) decodeApplication(body io.ReadCloser, err error) (*Application, error) {
 	if err != nil {
 		return nil, err
 	}
 
 	var application Application
-	if err = ms.Decode(data, &application); err != nil {
+	if err = decodeBody(body, &application); err != nil {
 		return nil, err
 	}
 
 	return &application, nil
 }
 
-func (api *API) decodeApplications(data interface{}, err error) (*[]Application, error) {
+func (api *API) decodeApplications(body io.ReadCloser, err error) (*[]Application, error) {
 	if err != nil {
 		return nil, err
 	}
 
 	var applications []Application
-	if err = ms.Decode(data, &applications); err != nil {
+	if err = decodeBody(body, &applications); err != nil {
 		return nil, err
 	}
 
 	return &applications, nil
 }
 
-func (api *API) decodeDeployment(data interface{}, err error) (*Deployment, error) {
+func (api *API) decodeDeployment(body io.ReadCloser, err error) (*Deployment, error) {
 	if err != nil {
 		return nil, err
 	}
 
 	var deployment Deployment
-	if err = ms.Decode(data, &deployment); err != nil {
+	if err = decodeBody(body, &deployment); err != nil {
 		return nil, err
 	}
 
 	return &deployment, nil
 }
 
-func (api *API) decodeDeployments(data interface{}, err error) (*[]Deployment, error) {
+func (api *API) decodeDeployments(body io.ReadCloser, err error) (*[]Deployment, error) {
 	if err != nil {
 		return nil, err
 	}
 
 	var deployments []Deployment
-	if err = ms.Decode(data, &deployments); err != nil {
+	if err = decodeBody(body, &deployments); err != nil {
 		return nil, err
 	}
 
 	return &deployments, nil
 }
 
-func (api *API) decodeAlias(data interface{}, err error) (*Alias, error) {
+func (api *API) decodeAlias(body io.ReadCloser, err error) (*Alias, error) {
 	if err != nil {
 		return nil, err
 	}
 
 	var alias Alias
-	if err = ms.Decode(data, &alias); err != nil {
+	if err = decodeBody(body, &alias); err != nil {
 		return nil, err
 	}
 
 	return &alias, nil
 }
 
-func (api *API) decodeAliases(data interface{}, err error) (*[]Alias, error) {
+func (api *API) decodeAliases(body io.ReadCloser, err error) (*[]Alias, error) {
 	if err != nil {
 		return nil, err
 	}
 
 	var aliases []Alias
-	if err = ms.Decode(data, &aliases); err != nil {
+	if err = decodeBody(body, &aliases); err != nil {
 		return nil, err
 	}
 
 	return &aliases, nil
 }
 
-func (api *API) decodeWorker(data interface{}, err error) (*Worker, error) {
+func (api *API) decodeWorker(body io.ReadCloser, err error) (*Worker, error) {
 	if err != nil {
 		return nil, err
 	}
 
 	var worker Worker
-	if err = ms.Decode(data, &worker); err != nil {
+	if err = decodeBody(body, &worker); err != nil {
 		return nil, err
 	}
 
 	return &worker, nil
 }
 
-func (api *API) decodeWorkers(data interface{}, err error) (*[]Worker, error) {
+func (api *API) decodeWorkers(body io.ReadCloser, err error) (*[]Worker, error) {
 	if err != nil {
 		return nil, err
 	}
 
 	var workers []Worker
-	if err = ms.Decode(data, &workers); err != nil {
+	if err = decodeBody(body, &workers); err != nil {
 		return nil, err
 	}
 
 	return &workers, nil
 }
 
-func (api *API) decodeCronjob(data interface{}, err error) (*Cronjob, error) {
+func (api *API) decodeCronjob(body io.ReadCloser, err error) (*Cronjob, error) {
 	if err != nil {
 		return nil, err
 	}
 
 	var cronjob Cronjob
-	if err = ms.Decode(data, &cronjob); err != nil {
+	if err = decodeBody(body, &cronjob); err != nil {
 		return nil, err
 	}
 
 	return &cronjob, nil
 }
 
-func (api *API) decodeCronjobs(data interface{}, err error) (*[]Cronjob, error) {
+func (api *API) decodeCronjobs(body io.ReadCloser, err error) (*[]Cronjob, error) {
 	if err != nil {
 		return nil, err
 	}
 
 	var cronjobs []Cronjob
-	if err = ms.Decode(data, &cronjobs); err != nil {
+	if err = decodeBody(body, &cronjobs); err != nil {
 		return nil, err
 	}
 
 	return &cronjobs, nil
 }
 
-func (api *API) decodeAddon(data interface{}, err error) (*Addon, error) {
+func (api *API) decodeAddon(body io.ReadCloser, err error) (*Addon, error) {
 	if err != nil {
 		return nil, err
 	}
 
 	var addon Addon
-	if err = ms.Decode(data, &addon); err != nil {
+	if err = decodeBody(body, &addon); err != nil {
 		return nil, err
 	}
 
 	return &addon, nil
 }
 
-func (api *API) decodeAddons(data interface{}, err error) (*[]Addon, error) {
+func (api *API) decodeAddons(body io.ReadCloser, err error) (*[]Addon, error) {
 	if err != nil {
 		return nil, err
 	}
 
 	var addons []Addon
-	if err = ms.Decode(data, &addons); err != nil {
+	if err = decodeBody(body, &addons); err != nil {
 		return nil, err
 	}
 
 	return &addons, nil
 }
 
-func (api *API) decodeUser(data interface{}, err error) (*User, error) {
+func (api *API) decodeUser(body io.ReadCloser, err error) (*User, error) {
 	if err != nil {
 		return nil, err
 	}
 
 	var user User
-	if err = ms.Decode(data, &user); err != nil {
+	if err = decodeBody(body, &user); err != nil {
 		return nil, err
 	}
 
 	return &user, nil
 }
 
-func (api *API) decodeUsers(data interface{}, err error) (*[]User, error) {
+func (api *API) decodeUsers(body io.ReadCloser, err error) (*[]User, error) {
 	if err != nil {
 		return nil, err
 	}
 
 	var users []User
-	if err = ms.Decode(data, &users); err != nil {
+	if err = decodeBody(body, &users); err != nil {
 		return nil, err
 	}
 
 	return &users, nil
 }
 
-func (api *API) decodeKey(data interface{}, err error) (*Key, error) {
+func (api *API) decodeKey(body io.ReadCloser, err error) (*Key, error) {
 	if err != nil {
 		return nil, err
 	}
 
 	var ey Key
-	if err = ms.Decode(data, &ey); err != nil {
+	if err = decodeBody(body, &ey); err != nil {
 		return nil, err
 	}
 
 	return &ey, nil
 }
 
-func (api *API) decodeKeys(data interface{}, err error) (*[]Key, error) {
+func (api *API) decodeKeys(body io.ReadCloser, err error) (*[]Key, error) {
 	if err != nil {
 		return nil, err
 	}
 
 	var eys []Key
-	if err = ms.Decode(data, &eys); err != nil {
+	if err = decodeBody(body, &eys); err != nil {
 		return nil, err
 	}
 
 	return &eys, nil
 }
 
-func (api *API) decodeLog(data interface{}, err error) (*Log, error) {
+func (api *API) decodeLog(body io.ReadCloser, err error) (*Log, error) {
 	if err != nil {
 		return nil, err
 	}
 
 	var log Log
-	if err = ms.Decode(data, &log); err != nil {
+	if err = decodeBody(body, &log); err != nil {
 		return nil, err
 	}
 
 	return &log, nil
 }
 
-func (api *API) decodeLogs(data interface{}, err error) (*[]Log, error) {
+func (api *API) decodeLogs(body io.ReadCloser, err error) (*[]Log, error) {
 	if err != nil {
 		return nil, err
 	}
 
 	var logs []Log
-	if err = ms.Decode(data, &logs); err != nil {
+	if err = decodeBody(body, &logs); err != nil {
 		return nil, err
 	}
 
 	return &logs, nil
 }
 
-func (api *API) decodeBillingAccount(data interface{}, err error) (*BillingAccount, error) {
+func (api *API) decodeBillingAccount(body io.ReadCloser, err error) (*BillingAccount, error) {
 	if err != nil {
 		return nil, err
 	}
 
 	var billingAccount BillingAccount
-	if err = ms.Decode(data, &billingAccount); err != nil {
+	if err = decodeBody(body, &billingAccount); err != nil {
 		return nil, err
 	}
 
 	return &billingAccount, nil
 }
 
-func (api *API) decodeBillingAccounts(data interface{}, err error) (*[]BillingAccount, error) {
+func (api *API) decodeBillingAccounts(body io.ReadCloser, err error) (*[]BillingAccount, error) {
 	if err != nil {
 		return nil, err
 	}
 
 	var billingAccounts []BillingAccount
-	if err = ms.Decode(data, &billingAccounts); err != nil {
+	if err = decodeBody(body, &billingAccounts); err != nil {
 		return nil, err
 	}
 
@@ -0,0 +1,237 @@
+package cclib
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestCreateUserWithoutToken(t *testing.T) {
+	// Given
+	var authorization, username string
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		r.ParseForm()
+		username = r.PostForm.Get("username")
+		fmt.Fprint(w, `{"username": "john", "email": "john@example.org"}`)
+	})
+	defer server.Close()
+	api.ClearToken()
+
+	// When
+	user, err := api.CreateUser("john", "john@example.org", "secret")
+
+	// Then
+	if err != nil {
+		t.Fatalf(msgFail, "CreateUser", nil, err)
+	}
+	if user.Username != "john" || username != "john" {
+		t.Errorf(msgFail, "CreateUser", "john", user)
+	}
+	if authorization != "" {
+		t.Errorf(msgFail, "CreateUser and Authorization", "", authorization)
+	}
+}
+
+func TestActivateUserWithoutToken(t *testing.T) {
+	// Given
+	var method, path, code string
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		r.ParseForm()
+		code = r.PostForm.Get("activation_code")
+		fmt.Fprint(w, `{"username": "john"}`)
+	})
+	defer server.Close()
+	api.ClearToken()
+
+	// When
+	_, err := api.ActivateUser("john", "abc")
+
+	// Then
+	if err != nil {
+		t.Errorf(msgFail, "ActivateUser", nil, err)
+	}
+	if method != "PUT" || path != "/user/john/" || code != "abc" {
+		t.Errorf(msgFail, "ActivateUser", "PUT /user/john/ abc", method+" "+path+" "+code)
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	// Given
+	var method, path string
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		w.WriteHeader(204)
+	})
+	defer server.Close()
+
+	// When
+	err := api.DeleteUser("john")
+
+	// Then
+	if err != nil {
+		t.Errorf(msgFail, "DeleteUser", nil, err)
+	}
+	if method != "DELETE" || path != "/user/john/" {
+		t.Errorf(msgFail, "DeleteUser", "DELETE /user/john/", method+" "+path)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	// Given
+	var paths []string
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		w.WriteHeader(204)
+	})
+	defer server.Close()
+	api.ClearToken()
+
+	// When
+	errResend := api.ResendActivation("john@example.org")
+	errRequest := api.RequestPasswordReset("john@example.org")
+	errReset := api.ResetPassword("john@example.org", "code", "newsecret")
+
+	// Then
+	if errResend != nil || errRequest != nil || errReset != nil {
+		t.Errorf(msgFail, "ResendActivation and ResetPassword", nil, []error{errResend, errRequest, errReset})
+	}
+	expected := "[POST /user/activation/ POST /user/password_reset/ PUT /user/password_reset/]"
+	if fmt.Sprint(paths) != expected {
+		t.Errorf(msgFail, "ResendActivation and ResetPassword", expected, paths)
+	}
+}
+
+func TestAPIConcurrentUse(t *testing.T) {
+	// Given
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token/" {
+			fmt.Fprint(w, `{"token": "refreshed", "expires": "2222-3333"}`)
+			return
+		}
+		fmt.Fprint(w, `[{"name": "app"}]`)
+	})
+	defer server.Close()
+
+	// When
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := 0; i < 10; i++ {
+		wg.Add(4)
+		go func() {
+			defer wg.Done()
+			_, err := api.ReadApplications()
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			errs <- api.CreateToken("john@example.org", "secret")
+		}()
+		go func() {
+			defer wg.Done()
+			api.SetToken("1234567890", "")
+			errs <- api.RequiresToken()
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := api.IsTokenValid()
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	// Then
+	for err := range errs {
+		if err != nil {
+			t.Errorf(msgFail, "API concurrent use", nil, err)
+		}
+	}
+	if api.Token() == nil {
+		t.Errorf(msgFail, "API concurrent use and Token", "token", nil)
+	}
+}
+
+func TestNewAPITokenDoesNotChangeAPIURL(t *testing.T) {
+	// Given
+	defaultUrl := API_URL
+	if previous, ok := os.LookupEnv("CCTRL_API_URL"); ok {
+		defer os.Setenv("CCTRL_API_URL", previous)
+	} else {
+		defer os.Unsetenv("CCTRL_API_URL")
+	}
+	os.Setenv("CCTRL_API_URL", "https://custom.example.com")
+
+	// When
+	api := NewAPIToken("1234567890")
+
+	// Then
+	if api.Url() != "https://custom.example.com" {
+		t.Errorf(msgFail, "NewAPIToken and Url", "https://custom.example.com", api.Url())
+	}
+	if API_URL != defaultUrl {
+		t.Errorf(msgFail, "NewAPIToken and API_URL", defaultUrl, API_URL)
+	}
+}
+
+func TestSetUrlOnceServed(t *testing.T) {
+	// Given
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	})
+	defer server.Close()
+
+	// When
+	errBefore := api.SetTokenSourceUrl(server.URL + "/auth/")
+	_, errRead := api.ReadApplications()
+	errUrl := api.SetUrl("https://other.example.com")
+	errAddon := api.SetRegisterAddonUrl("https://other.example.com/provider/addons")
+
+	// Then
+	if errBefore != nil || errRead != nil {
+		t.Fatalf(msgFail, "SetTokenSourceUrl before a request", nil, fmt.Sprint(errBefore, errRead))
+	}
+	if api.TokenSourceUrl() != server.URL+"/auth/" {
+		t.Errorf(msgFail, "TokenSourceUrl", server.URL+"/auth/", api.TokenSourceUrl())
+	}
+	if errUrl == nil || api.Url() != server.URL {
+		t.Errorf(msgFail, "SetUrl once served", server.URL, fmt.Sprint(api.Url(), " ", errUrl))
+	}
+	if errAddon == nil || api.RegisterAddonUrl() != server.URL+"/provider/addons" {
+		t.Errorf(msgFail, "SetRegisterAddonUrl once served", server.URL+"/provider/addons", errAddon)
+	}
+}
+
+func TestReadDeploymentDecoding(t *testing.T) {
+	// Given
+	payload := `{"name": "app/default", "dep_id": "dep1234", "state": "deployed",
+		"min_boxes": 2, "max_boxes": 4, "stack": {"name": "pinky"},
+		"billed_addons": [{"addon": "mysqls.free", "hours": 10.5, "costs": 0.5}]}`
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/app/broken/deployment/default/" {
+			fmt.Fprint(w, `{"name": "app/default", "min_boxes": "two"}`)
+			return
+		}
+		fmt.Fprint(w, payload)
+	})
+	defer server.Close()
+
+	// When
+	dep, err := api.ReadDeployment("app", "default")
+	_, errMalformed := api.ReadDeployment("broken", "default")
+
+	// Then
+	if err != nil {
+		t.Fatalf(msgFail, "ReadDeployment", nil, err)
+	}
+	if dep.Id != "dep1234" || dep.Containers != 2 || dep.Size != 4 || dep.Stack.Name != "pinky" ||
+		len(dep.BilledAddons) != 1 || dep.BilledAddons[0].Name != "mysqls.free" || dep.BilledAddons[0].Costs != 0.5 {
+		t.Errorf(msgFail, "ReadDeployment", "app/default", dep)
+	}
+	if errMalformed == nil {
+		t.Errorf(msgFail, "ReadDeployment", "error", errMalformed)
+	}
+}
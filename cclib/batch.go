@@ -0,0 +1,217 @@
+package cclib
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// DefaultConcurrency is the number of requests
+// a BatchReader makes at the same time
+var DefaultConcurrency = 8
+
+// Inventory contains the full tree of resources
+// of a list of applications
+type Inventory struct {
+	Applications []InventoryApplication
+	// Errors contains every resource that could not be read
+	Errors []ResourceError
+}
+
+// InventoryApplication contains the deployments of an application
+type InventoryApplication struct {
+	Name        string
+	Deployments []InventoryDeployment
+}
+
+// InventoryDeployment contains a deployment and its resources
+type InventoryDeployment struct {
+	Deployment Deployment
+	Addons     []Addon
+	Workers    []Worker
+	Aliases    []Alias
+}
+
+// ResourceError contains the error returned while
+// reading a resource of an application or deployment
+type ResourceError struct {
+	Application string
+	Deployment  string
+	// Resource is one of deployments, addons, workers or aliases
+	Resource string
+	Err      error
+}
+
+func (e ResourceError) Error() string {
+	if e.Deployment == "" {
+		return fmt.Sprintf("%s of %s: %v", e.Resource, e.Application, e.Err)
+	}
+	return fmt.Sprintf("%s of %s/%s: %v", e.Resource, e.Application, e.Deployment, e.Err)
+}
+
+// BatchError is returned if some resources
+// of a batch could not be read
+type BatchError struct {
+	Errors []ResourceError
+}
+
+func (e *BatchError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d resources could not be read: %s", len(e.Errors), strings.Join(messages, "; "))
+}
+
+// BatchProgress is sent to the progress callback every
+// time a resource has been read
+type BatchProgress struct {
+	// Done and Total count the requests, Total grows as
+	// deployments are discovered
+	Done        int
+	Total       int
+	Application string
+	Deployment  string
+	Resource    string
+	Err         error
+}
+
+// BatchReader reads many resources with a bounded
+// number of concurrent requests
+type BatchReader struct {
+	Api         *API
+	Concurrency int
+	// Progress is called after every request, optional.
+	// Calls are never concurrent.
+	Progress func(BatchProgress)
+
+	mu        sync.Mutex
+	wg        sync.WaitGroup
+	sem       chan bool
+	inventory *Inventory
+	done      int
+	total     int
+}
+
+// NewBatchReader creates a BatchReader for an API
+// with DefaultConcurrency.
+//
+// Returns a new BatchReader pointer
+func NewBatchReader(api *API) *BatchReader {
+	return &BatchReader{Api: api, Concurrency: DefaultConcurrency}
+}
+
+// ReadInventory reads the deployments of the given applications
+// and the addons, workers and aliases of every deployment
+// using a BatchReader with DefaultConcurrency.
+func (api *API) ReadInventory(appNames []string) (_ *Inventory, err error) {
+	op := api.begin("ReadInventory")
+	defer func() { op.end(err) }()
+	api = api.within(op)
+
+	return NewBatchReader(api).Read(appNames)
+}
+
+// Read reads the deployments of the given applications and the
+// addons, workers and aliases of every deployment.
+// A BatchReader must not run several Read at the same time.
+//
+// Returns the Inventory, with every resource that could be read,
+// and a *BatchError if some of them failed.
+func (reader *BatchReader) Read(appNames []string) (*Inventory, error) {
+	concurrency := reader.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	reader.sem = make(chan bool, concurrency)
+	reader.inventory = &Inventory{Applications: make([]InventoryApplication, len(appNames))}
+	reader.done, reader.total = 0, len(appNames)
+
+	for i, appName := range appNames {
+		reader.inventory.Applications[i].Name = appName
+		reader.spawn(func(i int, appName string) func() {
+			return func() { reader.readDeployments(i, appName) }
+		}(i, appName))
+	}
+	reader.wg.Wait()
+
+	inventory := reader.inventory
+	reader.inventory = nil
+	if len(inventory.Errors) > 0 {
+		return inventory, &BatchError{inventory.Errors}
+	}
+	return inventory, nil
+}
+
+func (reader *BatchReader) spawn(task func()) {
+	reader.wg.Add(1)
+	go func() {
+		defer reader.wg.Done()
+		reader.sem <- true
+		defer func() { <-reader.sem }()
+		task()
+	}()
+}
+
+func (reader *BatchReader) readDeployments(i int, appName string) {
+	deployments, err := reader.Api.ReadDeployments(appName)
+
+	reader.mu.Lock()
+	defer reader.mu.Unlock()
+	defer reader.progress(appName, "", "deployments", err)
+
+	if err != nil {
+		return
+	}
+
+	app := &reader.inventory.Applications[i]
+	app.Deployments = make([]InventoryDeployment, len(*deployments))
+	for j, dep := range *deployments {
+		app.Deployments[j].Deployment = dep
+		reader.total += 3
+
+		j, depName := j, deploymentName(dep.Name)
+		reader.spawn(func() {
+			addons, err := reader.Api.ReadAddons(appName, depName)
+			reader.store(appName, depName, "addons", err, func() {
+				reader.inventory.Applications[i].Deployments[j].Addons = *addons
+			})
+		})
+		reader.spawn(func() {
+			workers, err := reader.Api.ReadWorkers(appName, depName)
+			reader.store(appName, depName, "workers", err, func() {
+				reader.inventory.Applications[i].Deployments[j].Workers = *workers
+			})
+		})
+		reader.spawn(func() {
+			aliases, err := reader.Api.ReadAliases(appName, depName)
+			reader.store(appName, depName, "aliases", err, func() {
+				reader.inventory.Applications[i].Deployments[j].Aliases = *aliases
+			})
+		})
+	}
+}
+
+// store saves a read resource, if err is nil, and reports the progress
+func (reader *BatchReader) store(appName, depName, resource string, err error, save func()) {
+	reader.mu.Lock()
+	defer reader.mu.Unlock()
+
+	if err == nil {
+		save()
+	}
+	reader.progress(appName, depName, resource, err)
+}
+
+// progress must be called holding the mutex
+func (reader *BatchReader) progress(appName, depName, resource string, err error) {
+	reader.done++
+	if err != nil {
+		reader.inventory.Errors = append(reader.inventory.Errors, ResourceError{appName, depName, resource, err})
+	}
+
+	if reader.Progress != nil {
+		reader.Progress(BatchProgress{reader.done, reader.total, appName, depName, resource, err})
+	}
+}
@@ -0,0 +1,74 @@
+package cclib
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestBatchReaderRead(t *testing.T) {
+	// Given
+	var mu sync.Mutex
+	current, maxCurrent := 0, 0
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		current++
+		if current > maxCurrent {
+			maxCurrent = current
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			current--
+			mu.Unlock()
+		}()
+
+		switch {
+		case r.URL.Path == "/app/broken/deployment/":
+			w.WriteHeader(500)
+		case strings.HasSuffix(r.URL.Path, "/deployment/"):
+			fmt.Fprint(w, `[{"name": "x/default"}, {"name": "x/staging"}]`)
+		case strings.HasSuffix(r.URL.Path, "/staging/worker/"):
+			w.WriteHeader(503)
+		case strings.HasSuffix(r.URL.Path, "/addon/"):
+			fmt.Fprint(w, `[{"name": "mysqls.free"}]`)
+		case strings.HasSuffix(r.URL.Path, "/worker/"):
+			fmt.Fprint(w, `[{"wrk_id": "wrk1"}]`)
+		case strings.HasSuffix(r.URL.Path, "/alias/"):
+			fmt.Fprint(w, `[{"name": "x.example.com"}]`)
+		}
+	})
+	defer server.Close()
+
+	var progress []BatchProgress
+	reader := NewBatchReader(api)
+	reader.Concurrency = 2
+	reader.Progress = func(p BatchProgress) {
+		progress = append(progress, p)
+	}
+
+	// When
+	inventory, err := reader.Read([]string{"app1", "broken", "app2"})
+
+	// Then
+	batchErr, ok := err.(*BatchError)
+	if !ok || len(batchErr.Errors) != 3 {
+		t.Fatalf(msgFail, "BatchReader.Read", "3 errors", err)
+	}
+	if len(inventory.Applications) != 3 || inventory.Applications[1].Name != "broken" {
+		t.Errorf(msgFail, "BatchReader.Read", "3 applications", inventory.Applications)
+	}
+	dep := inventory.Applications[2].Deployments[1]
+	if dep.Deployment.Name != "x/staging" || len(dep.Addons) != 1 || len(dep.Aliases) != 1 || dep.Workers != nil {
+		t.Errorf(msgFail, "BatchReader.Read", "x/staging", dep)
+	}
+	if maxCurrent > 2 {
+		t.Errorf(msgFail, "BatchReader.Read and Concurrency", 2, maxCurrent)
+	}
+	last := progress[len(progress)-1]
+	if len(progress) != 15 || last.Done != 15 || last.Total != 15 {
+		t.Errorf(msgFail, "BatchReader.Read and Progress", 15, last)
+	}
+}
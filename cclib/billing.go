@@ -0,0 +1,199 @@
+package cclib
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BillingReport contains the costs of every application
+// and deployment of the current user
+type BillingReport struct {
+	Generated    time.Time         `json:"generated"`
+	Applications []ApplicationCost `json:"applications"`
+	Boxes        int               `json:"boxes"`
+	FreeBoxes    int               `json:"free_boxes"`
+	Costs        float64           `json:"costs"`
+	// Projected contains the estimated costs at the end of the month
+	Projected float64 `json:"projected"`
+}
+
+// ApplicationCost contains the costs of an application
+type ApplicationCost struct {
+	Name        string           `json:"name"`
+	Deployments []DeploymentCost `json:"deployments"`
+	Costs       float64          `json:"costs"`
+	Projected   float64          `json:"projected"`
+}
+
+// DeploymentCost contains the costs of a deployment
+type DeploymentCost struct {
+	Name      string  `json:"name"`
+	Boxes     int     `json:"boxes"`
+	FreeBoxes int     `json:"free_boxes"`
+	BoxCosts  float64 `json:"box_costs"`
+	// BoxProjected contains the estimated box costs
+	// at the end of the month
+	BoxProjected float64     `json:"box_projected"`
+	Addons       []AddonCost `json:"addons"`
+	Costs        float64     `json:"costs"`
+	Projected    float64     `json:"projected"`
+}
+
+// AddonCost contains the costs of a deployment add-on
+type AddonCost struct {
+	Name      string  `json:"name"`
+	Hours     float64 `json:"hours"`
+	Costs     float64 `json:"costs"`
+	Projected float64 `json:"projected"`
+}
+
+// ReadBillingReport reads every application and deployment
+// of the current user and builds a BillingReport.
+//
+// Returns the BillingReport
+// and an error if any request does not success.
+func (api *API) ReadBillingReport() (_ *BillingReport, err error) {
+	op := api.begin("ReadBillingReport")
+	defer func() { op.end(err) }()
+	api = api.within(op)
+
+	apps, err := api.ReadApplications()
+	if err != nil {
+		return nil, err
+	}
+
+	for i, app := range *apps {
+		deployments, err := api.ReadDeployments(app.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		for j, dep := range *deployments {
+			detailed, err := api.ReadDeployment(app.Name, deploymentName(dep.Name))
+			if err != nil {
+				return nil, err
+			}
+			(*deployments)[j] = *detailed
+		}
+
+		(*apps)[i].Deployments = *deployments
+	}
+
+	return NewBillingReport(*apps, time.Now()), nil
+}
+
+// NewBillingReport builds a BillingReport from a list of applications
+// having their deployments billing details. Costs are projected to the
+// end of the month of now, based on the time they were billed until.
+func NewBillingReport(apps []Application, now time.Time) *BillingReport {
+	report := &BillingReport{Generated: now}
+
+	for _, app := range apps {
+		appCost := ApplicationCost{Name: app.Name}
+
+		for _, dep := range app.Deployments {
+			boxes := dep.BilledBoxes
+			depCost := DeploymentCost{
+				Name:      deploymentName(dep.Name),
+				Boxes:     boxes.Boxes,
+				FreeBoxes: boxes.FreeBoxes,
+				BoxCosts:  float64(boxes.Costs),
+			}
+			depCost.BoxProjected = projectCosts(depCost.BoxCosts, boxes.Until, now)
+			depCost.Costs = depCost.BoxCosts
+			depCost.Projected = depCost.BoxProjected
+
+			for _, billed := range dep.BilledAddons {
+				addonCost := AddonCost{
+					Name:      billed.Name,
+					Hours:     billed.Hours,
+					Costs:     billed.Costs,
+					Projected: projectCosts(billed.Costs, billed.Until, now),
+				}
+				depCost.Addons = append(depCost.Addons, addonCost)
+				depCost.Costs += addonCost.Costs
+				depCost.Projected += addonCost.Projected
+			}
+
+			appCost.Deployments = append(appCost.Deployments, depCost)
+			appCost.Costs += depCost.Costs
+			appCost.Projected += depCost.Projected
+			report.Boxes += depCost.Boxes
+			report.FreeBoxes += depCost.FreeBoxes
+		}
+
+		report.Applications = append(report.Applications, appCost)
+		report.Costs += appCost.Costs
+		report.Projected += appCost.Projected
+	}
+
+	return report
+}
+
+// WriteJSON writes the report in json format
+func (report *BillingReport) WriteJSON(w io.Writer) error {
+	return json.NewEncoder(w).Encode(report)
+}
+
+// WriteCSV writes the report in csv format,
+// one line per deployment boxes and add-on
+func (report *BillingReport) WriteCSV(w io.Writer) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"application", "deployment", "item", "quantity", "free_boxes", "costs", "projected"})
+
+	for _, app := range report.Applications {
+		for _, dep := range app.Deployments {
+			writer.Write([]string{
+				app.Name, dep.Name, "boxes",
+				strconv.Itoa(dep.Boxes), strconv.Itoa(dep.FreeBoxes),
+				formatCosts(dep.BoxCosts), formatCosts(dep.BoxProjected),
+			})
+			for _, addon := range dep.Addons {
+				writer.Write([]string{
+					app.Name, dep.Name, addon.Name,
+					strconv.FormatFloat(addon.Hours, 'f', -1, 64), "",
+					formatCosts(addon.Costs), formatCosts(addon.Projected),
+				})
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// projectCosts extrapolates costs billed until a unix timestamp
+// to the end of the month of now
+func projectCosts(costs float64, until float64, now time.Time) float64 {
+	monthStart := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+	monthEnd := monthStart.AddDate(0, 1, 0)
+
+	billedUntil := now
+	if until > 0 {
+		billedUntil = time.Unix(int64(until), 0)
+	}
+
+	elapsed := billedUntil.Sub(monthStart)
+	if elapsed <= 0 || billedUntil.After(monthEnd) {
+		return costs
+	}
+
+	return costs * float64(monthEnd.Sub(monthStart)) / float64(elapsed)
+}
+
+func formatCosts(costs float64) string {
+	return strconv.FormatFloat(costs, 'f', 2, 64)
+}
+
+// deploymentName removes the application prefix of
+// a deployment name, eg: myapp/default -> default
+func deploymentName(name string) string {
+	if i := strings.Index(name, "/"); i >= 0 {
+		return name[i+1:]
+	}
+	return name
+}
@@ -0,0 +1,105 @@
+package cclib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewBillingReport(t *testing.T) {
+	// Given
+	now := time.Date(2014, 4, 16, 0, 0, 0, 0, time.UTC)
+	halfMonth := float64(now.Unix())
+	apps := []Application{{
+		Name: "app",
+		Deployments: []Deployment{{
+			Name:         "app/default",
+			BilledBoxes:  Boxes{Boxes: 3, Costs: 10, FreeBoxes: 1, Until: halfMonth},
+			BilledAddons: []BilledAddon{{Name: "mysqls.free", Hours: 360, Costs: 5, Until: halfMonth}},
+		}},
+	}}
+
+	// When
+	report := NewBillingReport(apps, now)
+
+	// Then
+	if report.Costs != 15 {
+		t.Errorf(msgFail, "NewBillingReport and Costs", 15, report.Costs)
+	}
+	if report.Projected != 30 {
+		t.Errorf(msgFail, "NewBillingReport and Projected", 30, report.Projected)
+	}
+	if report.Boxes != 3 || report.FreeBoxes != 1 {
+		t.Errorf(msgFail, "NewBillingReport and Boxes", "3 and 1", report)
+	}
+	dep := report.Applications[0].Deployments[0]
+	if dep.Name != "default" || dep.BoxCosts != 10 || dep.BoxProjected != 20 || dep.Addons[0].Projected != 10 {
+		t.Errorf(msgFail, "NewBillingReport and Deployments", "default", dep)
+	}
+}
+
+func TestBillingReportWriteCSV(t *testing.T) {
+	// Given
+	report := &BillingReport{Applications: []ApplicationCost{{
+		Name: "app",
+		Deployments: []DeploymentCost{{
+			Name: "default", Boxes: 2, FreeBoxes: 1, BoxCosts: 1.5, BoxProjected: 3, Costs: 3.5, Projected: 7,
+			Addons: []AddonCost{{Name: "mysqls.free", Hours: 10, Costs: 2, Projected: 4}},
+		}},
+	}}}
+	expected := "application,deployment,item,quantity,free_boxes,costs,projected\n" +
+		"app,default,boxes,2,1,1.50,3.00\n" +
+		"app,default,mysqls.free,10,,2.00,4.00\n"
+
+	// When
+	var buf bytes.Buffer
+	err := report.WriteCSV(&buf)
+
+	// Then
+	if err != nil {
+		t.Errorf(msgFail, "WriteCSV", nil, err)
+	}
+	if buf.String() != expected {
+		t.Errorf(msgFail, "WriteCSV", expected, buf.String())
+	}
+}
+
+func TestReadBillingReport(t *testing.T) {
+	// Given
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app/":
+			fmt.Fprint(w, `[{"name": "app"}]`)
+		case "/app/app/deployment/":
+			fmt.Fprint(w, `[{"name": "app/default"}]`)
+		case "/app/app/deployment/default/":
+			fmt.Fprint(w, `{"name": "app/default", "boxes": {"boxes": 2, "costs": 4.5, "free_boxes": 1}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	defer server.Close()
+
+	// When
+	report, err := api.ReadBillingReport()
+
+	// Then
+	if err != nil {
+		t.Fatalf(msgFail, "ReadBillingReport", nil, err)
+	}
+	if report.Costs != 4.5 {
+		t.Errorf(msgFail, "ReadBillingReport", 4.5, report.Costs)
+	}
+
+	var buf bytes.Buffer
+	report.WriteJSON(&buf)
+	var decoded BillingReport
+	json.Unmarshal(buf.Bytes(), &decoded)
+	if decoded.Applications[0].Deployments[0].BoxCosts != 4.5 || !strings.Contains(buf.String(), `"box_costs":4.5`) {
+		t.Errorf(msgFail, "WriteJSON", 4.5, buf.String())
+	}
+}
@@ -0,0 +1,187 @@
+package cclib
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// countryCodes contains the ISO 3166-1 alpha-2 country codes
+var countryCodes = map[string]bool{}
+
+var vatIdRegexp = regexp.MustCompile(`^[A-Z]{2}[0-9A-Z+*.]{2,12}$`)
+
+func init() {
+	for _, code := range strings.Fields(`
+		AD AE AF AG AI AL AM AO AQ AR AS AT AU AW AX AZ BA BB BD BE BF BG BH BI BJ
+		BL BM BN BO BQ BR BS BT BV BW BY BZ CA CC CD CF CG CH CI CK CL CM CN CO CR
+		CU CV CW CX CY CZ DE DJ DK DM DO DZ EC EE EG EH ER ES ET FI FJ FK FM FO FR
+		GA GB GD GE GF GG GH GI GL GM GN GP GQ GR GS GT GU GW GY HK HM HN HR HT HU
+		ID IE IL IM IN IO IQ IR IS IT JE JM JO JP KE KG KH KI KM KN KP KR KW KY KZ
+		LA LB LC LI LK LR LS LT LU LV LY MA MC MD ME MF MG MH MK ML MM MN MO MP MQ
+		MR MS MT MU MV MW MX MY MZ NA NC NE NF NG NI NL NO NP NR NU NZ OM PA PE PF
+		PG PH PK PL PM PN PR PS PT PW PY QA RE RO RS RU RW SA SB SC SD SE SG SH SI
+		SJ SK SL SM SN SO SR SS ST SV SX SY SZ TC TD TF TG TH TJ TK TL TM TN TO TR
+		TT TV TW TZ UA UG UM US UY UZ VA VC VE VG VI VN VU WF WS YE YT ZA ZM ZW`) {
+		countryCodes[code] = true
+	}
+}
+
+// BillingData contains the details to create or update
+// a billing account
+type BillingData struct {
+	Title      string
+	FirstName  string
+	SecondName string
+	Company    string
+	Email      string
+	Address    string
+	City       string
+	PostalCode string
+	// Country is an ISO 3166-1 alpha-2 code, eg: DE
+	Country string
+	// VatId is optional and must start with the country
+	// prefix, eg: DE123456789
+	VatId string
+	// SupportPlan name, optional
+	SupportPlan string
+}
+
+// BillingDataError contains every problem found
+// while validating billing data
+type BillingDataError struct {
+	Problems []string
+}
+
+func (e *BillingDataError) Error() string {
+	return fmt.Sprintf("Invalid billing data: %s.", strings.Join(e.Problems, "; "))
+}
+
+// Values returns the billing data in the format
+// expected by the API, empty fields are omitted
+func (data BillingData) Values() url.Values {
+	values := url.Values{}
+	fields := []struct{ key, value string }{
+		{"title", data.Title},
+		{"first_name", data.FirstName},
+		{"second_name", data.SecondName},
+		{"company", data.Company},
+		{"email", data.Email},
+		{"address", data.Address},
+		{"city", data.City},
+		{"postal_code", data.PostalCode},
+		{"country", strings.ToUpper(data.Country)},
+		{"vat_id", strings.ToUpper(strings.Replace(data.VatId, " ", "", -1))},
+		{"support_plan", data.SupportPlan},
+	}
+
+	for _, field := range fields {
+		if field.value != "" {
+			values.Add(field.key, field.value)
+		}
+	}
+
+	return values
+}
+
+// Validate checks that the billing data is complete and valid.
+// Returns a *BillingDataError listing every problem found
+// or nil if data is valid.
+func (data BillingData) Validate() error {
+	return data.validate(false)
+}
+
+func (data BillingData) validate(partial bool) error {
+	e := &BillingDataError{}
+
+	if !partial {
+		required := []struct{ name, value string }{
+			{"first_name", data.FirstName},
+			{"second_name", data.SecondName},
+			{"email", data.Email},
+			{"address", data.Address},
+			{"city", data.City},
+			{"postal_code", data.PostalCode},
+			{"country", data.Country},
+		}
+		for _, field := range required {
+			if strings.TrimSpace(field.value) == "" {
+				e.Problems = append(e.Problems, field.name+" is required")
+			}
+		}
+	}
+
+	if data.Email != "" && !strings.Contains(strings.TrimPrefix(data.Email, "@"), "@") {
+		e.Problems = append(e.Problems, fmt.Sprintf("email %q is not valid", data.Email))
+	}
+
+	country := strings.ToUpper(data.Country)
+	if data.Country != "" && !countryCodes[country] {
+		e.Problems = append(e.Problems, fmt.Sprintf("country %q is not an ISO 3166-1 alpha-2 code", data.Country))
+	}
+
+	if data.VatId != "" {
+		vatId := strings.ToUpper(strings.Replace(data.VatId, " ", "", -1))
+		prefix := country
+		if prefix == "GR" {
+			prefix = "EL"
+		}
+
+		switch {
+		case !vatIdRegexp.MatchString(vatId):
+			e.Problems = append(e.Problems, fmt.Sprintf("vat_id %q is not valid", data.VatId))
+		case country != "" && !strings.HasPrefix(vatId, prefix):
+			e.Problems = append(e.Problems, fmt.Sprintf("vat_id %q must start with %s", data.VatId, prefix))
+		}
+	}
+
+	if len(e.Problems) > 0 {
+		return e
+	}
+	return nil
+}
+
+// CreateBillingAccountData creates a new billing account having:
+//
+// * User name
+//
+// * Billing name
+//
+// * Billing data, validated before sending
+//
+// Returns just created BillingAccount
+// and an error if data is not valid or request does not success.
+func (api *API) CreateBillingAccountData(userName, billingName string, data BillingData) (_ *BillingAccount, err error) {
+	op := api.begin("CreateBillingAccountData")
+	defer func() { op.end(err) }()
+	api = api.within(op)
+
+	if err := data.validate(false); err != nil {
+		return nil, err
+	}
+
+	return api.CreateBillingAccount(userName, billingName, data.Values())
+}
+
+// UpdateBillingAccountData updates an existing user's billing account having:
+//
+// * User name
+//
+// * Billing name
+//
+// * Billing data, only non empty fields are validated and sent
+//
+// Returns updated user's BillingAccount
+// and an error if data is not valid or request does not success.
+func (api *API) UpdateBillingAccountData(userName, billingName string, data BillingData) (_ *BillingAccount, err error) {
+	op := api.begin("UpdateBillingAccountData")
+	defer func() { op.end(err) }()
+	api = api.within(op)
+
+	if err := data.validate(true); err != nil {
+		return nil, err
+	}
+
+	return api.UpdateBillingAccount(userName, billingName, data.Values())
+}
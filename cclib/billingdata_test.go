@@ -0,0 +1,109 @@
+package cclib
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func validBillingData() BillingData {
+	return BillingData{
+		FirstName:  "John",
+		SecondName: "Doe",
+		Email:      "john@example.org",
+		Address:    "Main Street 1",
+		City:       "Berlin",
+		PostalCode: "10115",
+		Country:    "de",
+		VatId:      "DE 123456789",
+	}
+}
+
+func TestBillingDataValues(t *testing.T) {
+	// Given
+	data := validBillingData()
+	expectedValues := url.Values{
+		"first_name":  {"John"},
+		"second_name": {"Doe"},
+		"email":       {"john@example.org"},
+		"address":     {"Main Street 1"},
+		"city":        {"Berlin"},
+		"postal_code": {"10115"},
+		"country":     {"DE"},
+		"vat_id":      {"DE123456789"},
+	}
+
+	// When
+	values := data.Values()
+
+	// Then
+	if !reflect.DeepEqual(values, expectedValues) {
+		t.Errorf(msgFail, "Values", expectedValues, values)
+	}
+}
+
+func TestBillingDataValidate(t *testing.T) {
+	// Given
+	valid := validBillingData()
+	invalid := BillingData{Email: "john", Country: "XX", VatId: "123"}
+	wrongPrefix := validBillingData()
+	wrongPrefix.VatId = "FR123456789"
+	expectedProblems := []string{
+		"first_name is required",
+		"second_name is required",
+		"address is required",
+		"city is required",
+		"postal_code is required",
+		`email "john" is not valid`,
+		`country "XX" is not an ISO 3166-1 alpha-2 code`,
+		`vat_id "123" is not valid`,
+	}
+
+	// When
+	errValid := valid.Validate()
+	errInvalid := invalid.Validate()
+	errPrefix := wrongPrefix.Validate()
+
+	// Then
+	if errValid != nil {
+		t.Errorf(msgFail, "Validate", nil, errValid)
+	}
+	dataErr, ok := errInvalid.(*BillingDataError)
+	if !ok || !reflect.DeepEqual(dataErr.Problems, expectedProblems) {
+		t.Errorf(msgFail, "Validate", expectedProblems, errInvalid)
+	}
+	if errPrefix == nil {
+		t.Errorf(msgFail, "Validate", "error", errPrefix)
+	}
+}
+
+func TestUpdateBillingAccountData(t *testing.T) {
+	// Given
+	var sent url.Values
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		sent = r.PostForm
+		fmt.Fprint(w, `{"name": "default", "city": "Hamburg"}`)
+	})
+	defer server.Close()
+
+	// When
+	account, err := api.UpdateBillingAccountData("john", "default", BillingData{City: "Hamburg"})
+	_, errInvalid := api.UpdateBillingAccountData("john", "default", BillingData{Country: "Germany"})
+
+	// Then
+	if err != nil {
+		t.Errorf(msgFail, "UpdateBillingAccountData", nil, err)
+	}
+	if account.City != "Hamburg" {
+		t.Errorf(msgFail, "UpdateBillingAccountData", "Hamburg", account.City)
+	}
+	if !reflect.DeepEqual(sent, url.Values{"city": {"Hamburg"}}) {
+		t.Errorf(msgFail, "UpdateBillingAccountData", "city=Hamburg", sent)
+	}
+	if errInvalid == nil {
+		t.Errorf(msgFail, "UpdateBillingAccountData", "error", errInvalid)
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"crypto/x509"
 )
 
+// SSL_CHECK and CA_CERTS are read by every request,
+// they must be set before any API is used
 var (
 	API_URL   = "https://api.cloudcontrolled.com"
 	SSL_CHECK = true
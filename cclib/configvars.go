@@ -0,0 +1,333 @@
+package cclib
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ConfigAddon is the name of the add-on which
+// provides configuration variables to a deployment
+var ConfigAddon = "config.free"
+
+// ConfigVars contains the configuration variables
+// of a deployment
+type ConfigVars map[string]string
+
+// ConfigDiff contains the differences between two
+// sets of configuration variables. Keys are sorted.
+type ConfigDiff struct {
+	// OnlyLeft contains the keys missing on the right side
+	OnlyLeft []string
+	// OnlyRight contains the keys missing on the left side
+	OnlyRight []string
+	// Changed contains the keys present on both sides
+	// with different values
+	Changed []string
+}
+
+// Keys returns the sorted configuration variable names
+func (vars ConfigVars) Keys() []string {
+	keys := make([]string, 0, len(vars))
+	for key := range vars {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Diff compares two sets of configuration variables
+func (vars ConfigVars) Diff(other ConfigVars) ConfigDiff {
+	var diff ConfigDiff
+	for _, key := range vars.Keys() {
+		value, ok := other[key]
+		switch {
+		case !ok:
+			diff.OnlyLeft = append(diff.OnlyLeft, key)
+		case value != vars[key]:
+			diff.Changed = append(diff.Changed, key)
+		}
+	}
+
+	for _, key := range other.Keys() {
+		if _, ok := vars[key]; !ok {
+			diff.OnlyRight = append(diff.OnlyRight, key)
+		}
+	}
+
+	return diff
+}
+
+// IsEmpty returns true if there are no differences
+func (diff ConfigDiff) IsEmpty() bool {
+	return len(diff.OnlyLeft) == 0 && len(diff.OnlyRight) == 0 && len(diff.Changed) == 0
+}
+
+// ReadEnvFile reads configuration variables from a .env file
+// given its path. Every line follows the format KEY=value, empty
+// lines and lines starting with # are ignored, an optional
+// `export ` prefix is allowed and values can be quoted.
+func ReadEnvFile(path string) (ConfigVars, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	vars := ConfigVars{}
+	scanner := bufio.NewScanner(f)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		line = strings.TrimPrefix(line, "export ")
+		i := strings.Index(line, "=")
+		if i < 1 {
+			return nil, fmt.Errorf("%s:%d: Line is not in KEY=value format.", path, lineNumber)
+		}
+
+		key := strings.TrimSpace(line[:i])
+		value, err := parseEnvValue(strings.TrimSpace(line[i+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+		}
+		vars[key] = value
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return vars, nil
+}
+
+func parseEnvValue(value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		return strconv.Unquote(value)
+	case strings.HasPrefix(value, "'"):
+		if len(value) < 2 || !strings.HasSuffix(value, "'") {
+			return "", fmt.Errorf("Value %s is not terminated.", value)
+		}
+		return value[1 : len(value)-1], nil
+	}
+
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value, nil
+}
+
+// WriteEnvFile writes the configuration variables in a
+// .env file given its path. Values are quoted if needed.
+func (vars ConfigVars) WriteEnvFile(path string) error {
+	var buf bytes.Buffer
+	for _, key := range vars.Keys() {
+		value := vars[key]
+		if value == "" || strings.ContainsAny(value, " \t\n\r#\"'\\") {
+			value = strconv.Quote(value)
+		}
+		fmt.Fprintf(&buf, "%s=%s\n", key, value)
+	}
+
+	return ioutil.WriteFile(path, buf.Bytes(), 0600)
+}
+
+// ReadConfigVars reads the configuration variables of a deployment having:
+//
+// * Application name
+//
+// * Deployment name
+//
+// Returns the ConfigVars, empty if the config add-on is not added,
+// and an error if request does not success.
+func (api *API) ReadConfigVars(appName, depName string) (_ ConfigVars, err error) {
+	op := api.begin("ReadConfigVars")
+	defer func() { op.end(err) }()
+	api = api.within(op)
+
+	vars, _, err := api.readConfigVars(appName, depName)
+	return vars, err
+}
+
+// SetConfigVars adds or updates configuration variables of a deployment having:
+//
+// * Application name
+//
+// * Deployment name
+//
+// * Configuration variables to set. Other existing variables are kept.
+//
+// The config add-on is created if the deployment does not have it yet.
+//
+// Returns all the resulting ConfigVars
+// and an error if request does not success.
+func (api *API) SetConfigVars(appName, depName string, vars ConfigVars) (_ ConfigVars, err error) {
+	op := api.begin("SetConfigVars")
+	defer func() { op.end(err) }()
+	api = api.within(op)
+
+	current, exists, err := api.readConfigVars(appName, depName)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range vars {
+		current[key] = value
+	}
+
+	return api.writeConfigVars(appName, depName, current, !exists)
+}
+
+// UnsetConfigVars removes configuration variables of a deployment having:
+//
+// * Application name
+//
+// * Deployment name
+//
+// * Names of the variables to remove
+//
+// Returns all the remaining ConfigVars
+// and an error if request does not success.
+func (api *API) UnsetConfigVars(appName, depName string, keys ...string) (_ ConfigVars, err error) {
+	op := api.begin("UnsetConfigVars")
+	defer func() { op.end(err) }()
+	api = api.within(op)
+
+	current, exists, err := api.readConfigVars(appName, depName)
+	if err != nil || !exists {
+		return current, err
+	}
+
+	for _, key := range keys {
+		delete(current, key)
+	}
+
+	return api.writeConfigVars(appName, depName, current, false)
+}
+
+// ImportEnvFile sets the configuration variables of a .env
+// file on a deployment, see SetConfigVars.
+func (api *API) ImportEnvFile(appName, depName, path string) (_ ConfigVars, err error) {
+	op := api.begin("ImportEnvFile")
+	defer func() { op.end(err) }()
+	api = api.within(op)
+
+	vars, err := ReadEnvFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return api.SetConfigVars(appName, depName, vars)
+}
+
+// ExportEnvFile writes the configuration variables of
+// a deployment in a .env file given its path.
+func (api *API) ExportEnvFile(appName, depName, path string) (err error) {
+	op := api.begin("ExportEnvFile")
+	defer func() { op.end(err) }()
+	api = api.within(op)
+
+	vars, err := api.ReadConfigVars(appName, depName)
+	if err != nil {
+		return err
+	}
+
+	return vars.WriteEnvFile(path)
+}
+
+// DiffConfigVars compares the configuration variables of two deployments having:
+//
+// * Left application name
+//
+// * Left deployment name
+//
+// * Right application name
+//
+// * Right deployment name
+//
+// Returns the ConfigDiff
+// and an error if any request does not success.
+func (api *API) DiffConfigVars(leftApp, leftDep, rightApp, rightDep string) (_ *ConfigDiff, err error) {
+	op := api.begin("DiffConfigVars")
+	defer func() { op.end(err) }()
+	api = api.within(op)
+
+	left, err := api.ReadConfigVars(leftApp, leftDep)
+	if err != nil {
+		return nil, err
+	}
+
+	right, err := api.ReadConfigVars(rightApp, rightDep)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := left.Diff(right)
+	return &diff, nil
+}
+
+// readConfigVars returns the configuration variables
+// and whether the config add-on exists
+func (api *API) readConfigVars(appName, depName string) (ConfigVars, bool, error) {
+	addon, err := api.ReadAddon(appName, depName, ConfigAddon)
+	if err != nil {
+		if isNotFound(err) {
+			return ConfigVars{}, false, nil
+		}
+		return nil, false, err
+	}
+
+	return configVarsFromSettings(addon.Settings), true, nil
+}
+
+func (api *API) writeConfigVars(appName, depName string, vars ConfigVars, isNew bool) (ConfigVars, error) {
+	settings := Settings{}
+	for key, value := range vars {
+		settings[key] = value
+	}
+
+	var err error
+	if isNew {
+		_, err = api.CreateAddon(appName, depName, ConfigAddon, &settings)
+	} else {
+		_, err = api.UpdateAddon(appName, depName, ConfigAddon, ConfigAddon, &settings, true)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return vars, nil
+}
+
+// configVarsFromSettings reads variables either from the
+// CONFIG_VARS setting or from the settings themselves
+func configVarsFromSettings(settings Settings) ConfigVars {
+	source := map[string]interface{}(settings)
+	if nested, ok := settings["CONFIG_VARS"].(map[string]interface{}); ok {
+		source = nested
+	}
+
+	vars := ConfigVars{}
+	for key, value := range source {
+		switch v := value.(type) {
+		case string:
+			vars[key] = v
+		case nil:
+			vars[key] = ""
+		default:
+			vars[key] = fmt.Sprint(v)
+		}
+	}
+
+	return vars
+}
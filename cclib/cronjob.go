@@ -0,0 +1,145 @@
+package cclib
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// CronjobSpec describes a cronjob as it should exist
+// on a deployment
+type CronjobSpec struct {
+	URL string `json:"url"`
+	// Interval is optional: hourly or daily
+	Interval string `json:"interval,omitempty"`
+}
+
+// CronjobSync contains the result of synchronizing
+// the cronjobs of a deployment
+type CronjobSync struct {
+	Created []Cronjob
+	Deleted []Cronjob
+	Kept    []Cronjob
+}
+
+// Validate returns an error if the spec can not
+// be used to create a cronjob
+func (spec CronjobSpec) Validate() error {
+	if spec.URL == "" {
+		return errors.New("Cronjob URL is required.")
+	}
+
+	switch spec.Interval {
+	case "", "hourly", "daily":
+		return nil
+	}
+
+	return fmt.Errorf("Cronjob interval %q is not valid.", spec.Interval)
+}
+
+// matches returns true if a cronjob fulfills the spec.
+// An empty Interval matches any interval.
+func (spec CronjobSpec) matches(cronjob Cronjob) bool {
+	if spec.URL != cronjob.URL {
+		return false
+	}
+
+	return spec.Interval == "" || spec.Interval == cronjob.Interval
+}
+
+// ReadCronjobSpecs reads a list of cronjob specs from
+// a json file given its path, eg:
+//
+//	[
+//		{"url": "http://myapp.cloudcontrolled.com/cron/clean"},
+//		{"url": "http://myapp.cloudcontrolled.com/cron/report", "interval": "daily"}
+//	]
+func ReadCronjobSpecs(path string) ([]CronjobSpec, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var specs []CronjobSpec
+	if err = json.Unmarshal(b, &specs); err != nil {
+		return nil, err
+	}
+
+	for _, spec := range specs {
+		if err = spec.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return specs, nil
+}
+
+// SyncCronjobs makes the cronjobs of a deployment match
+// a desired list having:
+//
+// * Application name
+//
+// * Deployment name
+//
+// * List of desired cronjob specs
+//
+// Missing cronjobs are created before the ones not present
+// in the desired list are deleted.
+//
+// Returns a CronjobSync with the applied changes
+// and an error if any request does not success. In that case
+// the CronjobSync contains the changes applied so far.
+func (api *API) SyncCronjobs(appName, depName string, desired []CronjobSpec) (_ *CronjobSync, err error) {
+	op := api.begin("SyncCronjobs")
+	defer func() { op.end(err) }()
+	api = api.within(op)
+
+	for _, spec := range desired {
+		if err := spec.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	existing, err := api.ReadCronjobs(appName, depName)
+	if err != nil {
+		return nil, err
+	}
+
+	sync := &CronjobSync{}
+	remaining := append([]Cronjob{}, *existing...)
+	var missing []CronjobSpec
+
+	for _, spec := range desired {
+		found := false
+		for i, cronjob := range remaining {
+			if spec.matches(cronjob) {
+				sync.Kept = append(sync.Kept, cronjob)
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			missing = append(missing, spec)
+		}
+	}
+
+	for _, spec := range missing {
+		cronjob, err := api.CreateCronjobSpec(appName, depName, spec)
+		if err != nil {
+			return sync, err
+		}
+		sync.Created = append(sync.Created, *cronjob)
+	}
+
+	for _, cronjob := range remaining {
+		if err := api.DeleteCronjob(appName, depName, cronjob.Id); err != nil {
+			return sync, err
+		}
+		sync.Deleted = append(sync.Deleted, cronjob)
+	}
+
+	return sync, nil
+}
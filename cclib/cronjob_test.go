@@ -0,0 +1,114 @@
+package cclib
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCronjobSpecValidate(t *testing.T) {
+	// Given
+	valid := CronjobSpec{URL: "http://app.example.com/cron", Interval: "daily"}
+	noURL := CronjobSpec{Interval: "hourly"}
+	badInterval := CronjobSpec{URL: "http://app.example.com/cron", Interval: "weekly"}
+
+	// When
+	errValid := valid.Validate()
+	errNoURL := noURL.Validate()
+	errBadInterval := badInterval.Validate()
+
+	// Then
+	if errValid != nil {
+		t.Errorf(msgFail, "Validate", nil, errValid)
+	}
+	if errNoURL == nil {
+		t.Errorf(msgFail, "Validate", "error", errNoURL)
+	}
+	if errBadInterval == nil {
+		t.Errorf(msgFail, "Validate", "error", errBadInterval)
+	}
+}
+
+func TestReadCronjobSpecs(t *testing.T) {
+	// Given
+	dir, err := ioutil.TempDir("", "cclib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	location := filepath.Join(dir, "cronjobs.json")
+	ioutil.WriteFile(location, []byte(`[
+		{"url": "http://app.example.com/clean"},
+		{"url": "http://app.example.com/report", "interval": "daily"}
+	]`), 0644)
+
+	expectedSpecs := []CronjobSpec{
+		{URL: "http://app.example.com/clean"},
+		{URL: "http://app.example.com/report", Interval: "daily"},
+	}
+
+	// When
+	specs, err := ReadCronjobSpecs(location)
+
+	// Then
+	if err != nil {
+		t.Errorf(msgFail, "ReadCronjobSpecs", nil, err)
+	}
+	if !reflect.DeepEqual(specs, expectedSpecs) {
+		t.Errorf(msgFail, "ReadCronjobSpecs", expectedSpecs, specs)
+	}
+}
+
+func TestSyncCronjobs(t *testing.T) {
+	// Given
+	var created, deleted []string
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/app/app/deployment/default/cron/":
+			fmt.Fprint(w, `[
+				{"job_id": "job1", "url": "http://app.example.com/clean", "interval": "hourly"},
+				{"job_id": "job2", "url": "http://app.example.com/old", "interval": "daily"}
+			]`)
+		case r.Method == "POST" && r.URL.Path == "/app/app/deployment/default/cron/":
+			r.ParseForm()
+			created = append(created, r.PostForm.Get("url"))
+			fmt.Fprintf(w, `{"job_id": "job3", "url": %q, "interval": %q}`,
+				r.PostForm.Get("url"), r.PostForm.Get("interval"))
+		case r.Method == "DELETE":
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(204)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	defer server.Close()
+
+	desired := []CronjobSpec{
+		{URL: "http://app.example.com/clean"},
+		{URL: "http://app.example.com/report", Interval: "daily"},
+	}
+
+	// When
+	sync, err := api.SyncCronjobs("app", "default", desired)
+
+	// Then
+	if err != nil {
+		t.Fatalf(msgFail, "SyncCronjobs", nil, err)
+	}
+	if len(sync.Kept) != 1 || sync.Kept[0].Id != "job1" {
+		t.Errorf(msgFail, "SyncCronjobs and Kept", "job1", sync.Kept)
+	}
+	if len(sync.Created) != 1 || sync.Created[0].Interval != "daily" {
+		t.Errorf(msgFail, "SyncCronjobs and Created", "job3", sync.Created)
+	}
+	if !reflect.DeepEqual(created, []string{"http://app.example.com/report"}) {
+		t.Errorf(msgFail, "SyncCronjobs and POST", "http://app.example.com/report", created)
+	}
+	if !reflect.DeepEqual(deleted, []string{"/app/app/deployment/default/cron/job2/"}) {
+		t.Errorf(msgFail, "SyncCronjobs and DELETE", "/app/app/deployment/default/cron/job2/", deleted)
+	}
+}
@@ -0,0 +1,325 @@
+package cclib
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Deployment states recorded in a DeployJournal
+const (
+	// DeployPushed is recorded when a version is pushed
+	DeployPushed = "pushed"
+	// DeployActive is recorded when a version is seen deployed,
+	// which makes it a known-good version to roll back to
+	DeployActive = "deployed"
+	// DeployFailed is the state of a deployment whose
+	// version failed to deploy, it is never recorded
+	DeployFailed = "failed"
+)
+
+// DeployRecord contains a journal entry of a deployment version
+type DeployRecord struct {
+	Time       time.Time `json:"time"`
+	App        string    `json:"app"`
+	Deployment string    `json:"deployment"`
+	Version    string    `json:"version"`
+	// State is DeployPushed or DeployActive
+	State string `json:"state"`
+	// Rollback is true if the version was pushed by RollbackDeployment
+	Rollback bool `json:"rollback,omitempty"`
+}
+
+// DeployJournal is a local file recording, one json object per
+// line, the versions pushed through an API and the versions seen
+// deployed, so deployments can be rolled back to them
+type DeployJournal struct {
+	Path string
+	// WaitInterval between two reads of a deployment
+	// waiting for a version, 10 seconds if 0
+	WaitInterval time.Duration
+	// WaitTimeout to wait for a version to be
+	// deployed, 10 minutes if 0
+	WaitTimeout time.Duration
+
+	mu sync.Mutex
+}
+
+// NewDeployJournal creates a DeployJournal writing to path,
+// waiting for versions every 10 seconds for up to 10 minutes.
+//
+// Returns a new DeployJournal pointer
+func NewDeployJournal(path string) *DeployJournal {
+	return &DeployJournal{
+		Path:         path,
+		WaitInterval: 10 * time.Second,
+		WaitTimeout:  10 * time.Minute,
+	}
+}
+
+// waitTimes returns the interval and timeout to wait for
+// a version, the defaults if there is no journal
+func (journal *DeployJournal) waitTimes() (interval, timeout time.Duration) {
+	interval, timeout = 10*time.Second, 10*time.Minute
+	if journal == nil {
+		return
+	}
+	if journal.WaitInterval > 0 {
+		interval = journal.WaitInterval
+	}
+	if journal.WaitTimeout > 0 {
+		timeout = journal.WaitTimeout
+	}
+	return
+}
+
+// Append adds a record to the journal, setting its time if zero
+func (journal *DeployJournal) Append(record DeployRecord) error {
+	if record.Time.IsZero() {
+		record.Time = time.Now().UTC()
+	}
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
+	f, err := os.OpenFile(journal.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err = f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Records returns the records of a deployment, oldest first.
+// A missing journal has no records.
+func (journal *DeployJournal) Records(appName, depName string) ([]DeployRecord, error) {
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
+	f, err := os.Open(journal.Path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []DeployRecord
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		var record DeployRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", journal.Path, n, err)
+		}
+		if record.App == appName && record.Deployment == depName {
+			records = append(records, record)
+		}
+	}
+
+	return records, scanner.Err()
+}
+
+// KnownGoodVersions returns the versions of a deployment
+// seen deployed, the most recently seen first
+func (journal *DeployJournal) KnownGoodVersions(appName, depName string) ([]string, error) {
+	records, err := journal.Records(appName, depName)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []string
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		if record.State == DeployActive && !containsString(versions, record.Version) {
+			versions = append(versions, record.Version)
+		}
+	}
+	return versions, nil
+}
+
+// SetDeployJournal sets the journal recording
+// the versions pushed with UpdateDeployment
+func (api *API) SetDeployJournal(journal *DeployJournal) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.journal = journal
+}
+
+// DeployJournal returns the API deploy journal
+func (api *API) DeployJournal() *DeployJournal {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	return api.journal
+}
+
+// ReadDeployHistory returns the journal records of a deployment having:
+//
+// * Application name
+//
+// * Deployment name
+//
+// Returns the records, oldest first, and an error
+// if the API has no journal or it can't be read.
+func (api *API) ReadDeployHistory(appName, depName string) ([]DeployRecord, error) {
+	journal := api.DeployJournal()
+	if journal == nil {
+		return nil, errors.New("Deploy journal is not set.")
+	}
+	return journal.Records(appName, depName)
+}
+
+// ReadDeployLog returns the deploy log entries of a deployment.
+//
+// Returns a list of Log and an error if request does not success.
+func (api *API) ReadDeployLog(appName, depName string) (_ *[]Log, err error) {
+	op := api.begin("ReadDeployLog")
+	defer func() { op.end(err) }()
+	api = api.within(op)
+
+	return api.ReadLog(appName, depName, "deploy", nil)
+}
+
+// RollbackDeployment redeploys a previous known-good version having:
+//
+// * Application name
+//
+// * Deployment name
+//
+// * Steps back among the known-good versions other than the
+// current one: 1 for the last one, 2 for the one before, etc.
+//
+// Then it waits, every WaitInterval and for up to WaitTimeout
+// of the journal, until the version is deployed. In
+// dry-run mode it returns as soon as the update is planned.
+//
+// Returns the rolled back Deployment and an error if the API has
+// no journal, there are not enough known-good versions, any request
+// does not success or the version fails or is not deployed in time.
+func (api *API) RollbackDeployment(appName, depName string, steps int) (_ *Deployment, err error) {
+	op := api.begin("RollbackDeployment")
+	defer func() { op.end(err) }()
+	api = api.within(op)
+
+	if depName == "" {
+		depName = "default"
+	}
+	if steps < 1 {
+		return nil, errors.New("Rollback steps must be 1 or more.")
+	}
+
+	journal := api.DeployJournal()
+	if journal == nil {
+		return nil, errors.New("Deploy journal is not set.")
+	}
+
+	current, err := api.ReadDeployment(appName, depName)
+	if err != nil {
+		return nil, err
+	}
+
+	good, err := journal.KnownGoodVersions(appName, depName)
+	if err != nil {
+		return nil, err
+	}
+
+	var candidates []string
+	for _, version := range good {
+		if !sameVersion(version, current.Version) {
+			candidates = append(candidates, version)
+		}
+	}
+	if len(candidates) < steps {
+		return nil, fmt.Errorf("Only %d known-good versions to roll back %s/%s to.", len(candidates), appName, depName)
+	}
+	version := candidates[steps-1]
+
+	deployment, err := api.updateDeployment(op, appName, depName, url.Values{"version": {version}}, true)
+	if err != nil || api.Plan() != nil {
+		return deployment, err
+	}
+
+	return api.waitForVersion(appName, depName, version, true)
+}
+
+// WaitForDeployment waits, every WaitInterval and for up to
+// WaitTimeout of the journal, if any, or every 10 seconds for up
+// to 10 minutes otherwise, until a deployment runs version,
+// recording it as known-good in the journal.
+//
+// Returns the Deployment and an error if any request does not
+// success or the version fails or is not deployed in time.
+func (api *API) WaitForDeployment(appName, depName, version string) (_ *Deployment, err error) {
+	op := api.begin("WaitForDeployment")
+	defer func() { op.end(err) }()
+	api = api.within(op)
+
+	if depName == "" {
+		depName = "default"
+	}
+	return api.waitForVersion(appName, depName, version, false)
+}
+
+func (api *API) waitForVersion(appName, depName, version string, rollback bool) (*Deployment, error) {
+	journal := api.DeployJournal()
+	interval, timeout := journal.waitTimes()
+	deadline := time.Now().Add(timeout)
+	for {
+		deployment, err := api.ReadDeployment(appName, depName)
+		if err != nil {
+			return nil, err
+		}
+
+		if sameVersion(deployment.Version, version) && deployment.State == DeployActive {
+			if journal != nil && api.Plan() == nil {
+				err = journal.Append(DeployRecord{
+					App:        appName,
+					Deployment: depName,
+					Version:    deployment.Version,
+					State:      DeployActive,
+					Rollback:   rollback,
+				})
+			}
+			return deployment, err
+		}
+
+		if sameVersion(deployment.Version, version) && deployment.State == DeployFailed {
+			return nil, fmt.Errorf("Version %s of %s/%s is %s.", version, appName, depName, deployment.State)
+		}
+
+		if !time.Now().Add(interval).Before(deadline) {
+			return nil, fmt.Errorf("Version %s of %s/%s was not deployed in %v, deployment is %s with version %s.",
+				version, appName, depName, timeout, deployment.State, deployment.Version)
+		}
+		time.Sleep(interval)
+	}
+}
+
+// sameVersion returns true if two versions are the same, one of
+// them being possibly a commit hash abbreviated to 7 or more characters
+func sameVersion(a, b string) bool {
+	if len(a) > len(b) {
+		a, b = b, a
+	}
+	return a == b || len(a) >= 7 && strings.HasPrefix(b, a)
+}
@@ -0,0 +1,199 @@
+package cclib
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeDeployment serves a deployment whose pushed versions are
+// deployed after being read once, or failed if they are broken
+type fakeDeployment struct {
+	mu      sync.Mutex
+	version string
+	state   string
+	broken  map[string]bool
+	pushed  []string
+}
+
+func (f *fakeDeployment) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if strings.Contains(r.URL.Path, "/log/deploy/") {
+		fmt.Fprint(w, `[{"type": "deploy", "message": "deployed", "time": 1}]`)
+		return
+	}
+
+	switch r.Method {
+	case "PUT":
+		r.ParseForm()
+		f.version = r.PostForm.Get("version")
+		f.state = "deploying"
+		f.pushed = append(f.pushed, f.version)
+		fmt.Fprintf(w, `{"name": "blog/default", "version": "%s", "state": "%s"}`, f.version, f.state)
+	default:
+		fmt.Fprintf(w, `{"name": "blog/default", "version": "%s", "state": "%s"}`, f.version, f.state)
+		if f.state == "deploying" {
+			f.state = DeployActive
+			if f.broken[f.version] {
+				f.state = DeployFailed
+			}
+		}
+	}
+}
+
+func newTestJournal(t *testing.T) (*DeployJournal, func()) {
+	dir, err := ioutil.TempDir("", "cclib")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	journal := NewDeployJournal(filepath.Join(dir, "deploys.json"))
+	journal.WaitInterval, journal.WaitTimeout = time.Millisecond, 50*time.Millisecond
+
+	return journal, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+func TestRollbackDeployment(t *testing.T) {
+	// Given
+	journal, cleanup := newTestJournal(t)
+	defer cleanup()
+
+	fake := &fakeDeployment{version: "v1", state: DeployActive, broken: map[string]bool{"v3": true}}
+	api, server := newTestAPI(fake.ServeHTTP)
+	defer server.Close()
+	api.SetDeployJournal(journal)
+
+	if _, err := api.UpdateDeployment("blog", "default", "v2", "", "", 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.WaitForDeployment("blog", "default", "v2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := api.UpdateDeployment("blog", "default", "v3", "", "", 0, 0); err != nil {
+		t.Fatal(err)
+	}
+	_, errWait := api.WaitForDeployment("blog", "default", "v3")
+
+	// When
+	deployment, err := api.RollbackDeployment("blog", "default", 1)
+
+	// Then
+	if errWait == nil || errWait.Error() != "Version v3 of blog/default is failed." {
+		t.Errorf(msgFail, "WaitForDeployment failed", "Version v3 of blog/default is failed.", errWait)
+	}
+	if err != nil {
+		t.Fatalf(msgFail, "RollbackDeployment", nil, err)
+	}
+	if deployment.Version != "v2" || deployment.State != DeployActive {
+		t.Errorf(msgFail, "RollbackDeployment", "v2 deployed", deployment.Version+" "+deployment.State)
+	}
+	if !reflect.DeepEqual(fake.pushed, []string{"v2", "v3", "v2"}) {
+		t.Errorf(msgFail, "RollbackDeployment pushed", []string{"v2", "v3", "v2"}, fake.pushed)
+	}
+
+	records, err := api.ReadDeployHistory("blog", "default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var states []string
+	for _, record := range records {
+		state := record.Version + " " + record.State
+		if record.Rollback {
+			state += " rollback"
+		}
+		states = append(states, state)
+	}
+	expected := []string{
+		"v1 deployed", "v2 pushed", "v2 deployed", "v2 deployed",
+		"v3 pushed", "v2 pushed rollback", "v2 deployed rollback",
+	}
+	if !reflect.DeepEqual(states, expected) {
+		t.Errorf(msgFail, "ReadDeployHistory", expected, states)
+	}
+
+	good, _ := journal.KnownGoodVersions("blog", "default")
+	if !reflect.DeepEqual(good, []string{"v2", "v1"}) {
+		t.Errorf(msgFail, "KnownGoodVersions", []string{"v2", "v1"}, good)
+	}
+}
+
+func TestRollbackDeploymentSteps(t *testing.T) {
+	// Given
+	journal, cleanup := newTestJournal(t)
+	defer cleanup()
+
+	fake := &fakeDeployment{version: "v3", state: DeployActive}
+	api, server := newTestAPI(fake.ServeHTTP)
+	defer server.Close()
+	api.SetDeployJournal(journal)
+
+	journal.Append(DeployRecord{App: "blog", Deployment: "default", Version: "v1", State: DeployActive})
+	journal.Append(DeployRecord{App: "blog", Deployment: "default", Version: "v2", State: DeployActive})
+	journal.Append(DeployRecord{App: "other", Deployment: "default", Version: "v0", State: DeployActive})
+
+	// When
+	_, errTooMany := api.RollbackDeployment("blog", "default", 3)
+	deployment, err := api.RollbackDeployment("blog", "default", 2)
+
+	// Then
+	if errTooMany == nil || errTooMany.Error() != "Only 2 known-good versions to roll back blog/default to." {
+		t.Errorf(msgFail, "RollbackDeployment steps", "Only 2 known-good versions", errTooMany)
+	}
+	if err != nil || deployment.Version != "v1" {
+		t.Errorf(msgFail, "RollbackDeployment 2 steps", "v1", fmt.Sprint(deployment, err))
+	}
+}
+
+func TestSameVersion(t *testing.T) {
+	versions := map[[2]string]bool{
+		{"abc", "abc"}:               true,
+		{"abc", "abcdef"}:            false,
+		{"abcdef1", "abcdef123456"}:  true,
+		{"abcdef123456", "abcdef1"}:  true,
+		{"abcdef1", "abcdef2345678"}: false,
+		{"", "abc"}:                  false,
+		{"", ""}:                     true,
+	}
+
+	for pair, expected := range versions {
+		if same := sameVersion(pair[0], pair[1]); same != expected {
+			t.Errorf(msgFail, "sameVersion "+pair[0]+" "+pair[1], expected, same)
+		}
+	}
+}
+
+func TestRollbackDeploymentWithoutJournal(t *testing.T) {
+	api := NewCustomAPI("https://api.invalid", NewToken("1234567890", ""), "", "")
+
+	if _, err := api.RollbackDeployment("blog", "default", 1); err == nil {
+		t.Errorf(msgFail, "RollbackDeployment without journal", "error", err)
+	}
+	if _, err := api.ReadDeployHistory("blog", "default"); err == nil {
+		t.Errorf(msgFail, "ReadDeployHistory without journal", "error", err)
+	}
+}
+
+func TestReadDeployLog(t *testing.T) {
+	// Given
+	api, server := newTestAPI((&fakeDeployment{}).ServeHTTP)
+	defer server.Close()
+
+	// When
+	logs, err := api.ReadDeployLog("blog", "default")
+
+	// Then
+	if err != nil || len(*logs) != 1 || (*logs)[0].Type != "deploy" {
+		t.Errorf(msgFail, "ReadDeployLog", "1 deploy log", fmt.Sprint(logs, err))
+	}
+}
@@ -0,0 +1,230 @@
+package cclib
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// PlannedCall contains a mutating call which
+// was not sent because of the dry-run mode
+type PlannedCall struct {
+	// API method, eg: DeleteApplication
+	Operation string
+	// HTTP method, eg: DELETE
+	Method   string
+	Resource string
+	// Data sent, with its secrets scrubbed
+	Data string
+}
+
+// String returns the call as "METHOD resource data (Operation)"
+func (call PlannedCall) String() string {
+	s := call.Method + " " + call.Resource
+	if call.Data != "" {
+		s += " " + call.Data
+	}
+	return s + " (" + call.Operation + ")"
+}
+
+// Plan contains the calls that an API in
+// dry-run mode would have sent
+type Plan struct {
+	mu    sync.Mutex
+	calls []PlannedCall
+}
+
+// Calls returns the planned calls in order
+func (plan *Plan) Calls() []PlannedCall {
+	plan.mu.Lock()
+	defer plan.mu.Unlock()
+	return append([]PlannedCall{}, plan.calls...)
+}
+
+// Reset removes the planned calls
+func (plan *Plan) Reset() {
+	plan.mu.Lock()
+	defer plan.mu.Unlock()
+	plan.calls = nil
+}
+
+// String returns the planned calls, one per line
+func (plan *Plan) String() string {
+	var buf bytes.Buffer
+	for _, call := range plan.Calls() {
+		buf.WriteString(call.String())
+		buf.WriteString("\n")
+	}
+	return buf.String()
+}
+
+// Write writes the planned calls to w, one per line
+func (plan *Plan) Write(w io.Writer) error {
+	_, err := io.WriteString(w, plan.String())
+	return err
+}
+
+// record adds a call to the plan and returns
+// the synthetic body of its response
+func (plan *Plan) record(operation, method, resource string, data []byte) io.ReadCloser {
+	plan.mu.Lock()
+	plan.calls = append(plan.calls, PlannedCall{
+		Operation: operation,
+		Method:    strings.ToUpper(method),
+		Resource:  resource,
+		Data:      scrubBody(data),
+	})
+	plan.mu.Unlock()
+
+	return newSyntheticBody(method, data)
+}
+
+// EnableDryRun starts the dry-run mode: POST, PUT and DELETE
+// requests, and so every method creating, updating or deleting
+// a resource, are added to the returned Plan instead of being
+// sent. GET requests and token creation still hit the API.
+//
+// Mutating methods return results of the right types, filled
+// with the data they would have sent when possible.
+func (api *API) EnableDryRun() *Plan {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if api.plan == nil {
+		api.plan = &Plan{}
+	}
+	return api.plan
+}
+
+// DisableDryRun stops the dry-run mode
+func (api *API) DisableDryRun() {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.plan = nil
+}
+
+// Plan returns the Plan of the dry-run mode,
+// nil if the API is not in dry-run mode
+func (api *API) Plan() *Plan {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	return api.plan
+}
+
+// isMutating returns true if an HTTP method changes resources
+func isMutating(method string) bool {
+	switch strings.ToUpper(method) {
+	case "GET", "HEAD", "OPTIONS":
+		return false
+	}
+	return true
+}
+
+// syntheticBody is the response body of a planned call.
+// It contains the sent data, which decodeBody reads as a
+// json object shaped after the type it decodes into.
+type syntheticBody struct {
+	io.Reader
+	values map[string]interface{}
+}
+
+func newSyntheticBody(method string, data []byte) *syntheticBody {
+	values := map[string]interface{}{}
+	if strings.ToUpper(method) != "DELETE" {
+		if isJSONData(data) {
+			json.Unmarshal(data, &values)
+		} else if form, err := url.ParseQuery(string(data)); err == nil {
+			for key := range form {
+				values[key] = form.Get(key)
+			}
+		}
+	}
+
+	body := &syntheticBody{values: values}
+	body.Reader = body.json(nil)
+	return body
+}
+
+func (body *syntheticBody) Close() error {
+	return nil
+}
+
+// json returns the values as a json object whose members
+// have the types of the fields of v with the same json
+// names, eg: min_boxes=2 as a number for a Deployment.
+// Returns an empty json stream if there are no values.
+func (body *syntheticBody) json(v interface{}) io.Reader {
+	if len(body.values) == 0 {
+		return bytes.NewReader(nil)
+	}
+
+	fields := jsonFields(reflect.TypeOf(v))
+	object := map[string]interface{}{}
+	for key, value := range body.values {
+		object[key] = syntheticValue(value, fields[key])
+	}
+
+	content, err := json.Marshal(object)
+	if err != nil {
+		return bytes.NewReader(nil)
+	}
+	return bytes.NewReader(content)
+}
+
+// jsonFields returns the types of the fields of
+// a struct, or a pointer to one, by json name
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := map[string]reflect.Type{}
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return fields
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if name := strings.Split(field.Tag.Get("json"), ",")[0]; name != "" {
+			fields[name] = field.Type
+		}
+	}
+	return fields
+}
+
+// syntheticValue converts a sent form value into the json
+// value of a field of type t, eg: a struct as its name, as
+// in stack=pinky, or a map as json, as in add-on settings.
+// Values which can't be converted are kept as strings.
+func syntheticValue(value interface{}, t reflect.Type) interface{} {
+	s, ok := value.(string)
+	if !ok || t == nil {
+		return value
+	}
+
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		if _, err := strconv.ParseFloat(s, 64); err == nil {
+			return json.Number(s)
+		}
+	case reflect.Bool:
+		if b, err := strconv.ParseBool(s); err == nil {
+			return b
+		}
+	case reflect.Struct:
+		if strings.HasPrefix(s, "{") && json.Valid([]byte(s)) {
+			return json.RawMessage(s)
+		}
+		return map[string]string{"name": s}
+	case reflect.Map, reflect.Slice:
+		if json.Valid([]byte(s)) {
+			return json.RawMessage(s)
+		}
+	}
+	return s
+}
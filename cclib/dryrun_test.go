@@ -0,0 +1,136 @@
+package cclib
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	// Given
+	var methods []string
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		methods = append(methods, r.Method)
+		fmt.Fprint(w, `{"name": "blog/default", "version": "abc", "min_boxes": 1}`)
+	})
+	defer server.Close()
+
+	plan := api.EnableDryRun()
+
+	// When
+	current, errRead := api.ReadDeployment("blog", "default")
+	dep, errUpdate := api.UpdateDeployment("blog", "default", "1234567", "", "", 2, 0)
+	user, errUser := api.CreateUser("john", "john@example.com", "secret")
+	errDelete := api.DeleteApplication("blog")
+
+	// Then
+	if errRead != nil || errUpdate != nil || errUser != nil || errDelete != nil {
+		t.Fatalf(msgFail, "DryRun", nil, fmt.Sprint(errRead, errUpdate, errUser, errDelete))
+	}
+	if !reflect.DeepEqual(methods, []string{"GET"}) {
+		t.Errorf(msgFail, "DryRun sent methods", []string{"GET"}, methods)
+	}
+	if current.Version != "abc" {
+		t.Errorf(msgFail, "DryRun Get", "abc", current.Version)
+	}
+	if dep.Version != "1234567" || dep.Containers != 2 {
+		t.Errorf(msgFail, "DryRun synthetic deployment", "1234567 2", fmt.Sprint(dep.Version, " ", dep.Containers))
+	}
+	if user.Username != "john" || user.Email != "john@example.com" {
+		t.Errorf(msgFail, "DryRun synthetic user", "john", user)
+	}
+
+	expected := []PlannedCall{
+		{"UpdateDeployment", "PUT", "/app/blog/deployment/default/", "min_boxes=2&version=1234567"},
+		{"CreateUser", "POST", "/user/", "email=john%40example.com&password=%2A%2A%2A%2A%2A&username=john"},
+		{"DeleteApplication", "DELETE", "/app/blog/", ""},
+	}
+	if calls := plan.Calls(); !reflect.DeepEqual(calls, expected) {
+		t.Errorf(msgFail, "Plan.Calls", expected, calls)
+	}
+}
+
+func TestDryRunSyntheticValues(t *testing.T) {
+	// Given
+	api := NewCustomAPI("https://api.invalid", NewToken("1234567890", ""), "", "")
+	api.EnableDryRun()
+	settings := Settings{"MYSQL_SIZE": "2"}
+
+	// When
+	dep, errDep := api.UpdateDeployment("blog", "default", "1234567", "", "pinky", 0, 0)
+	addon, errAddon := api.UpdateAddon("blog", "default", "mysql.free", "mysql.big", &settings, false)
+	var broken struct {
+		Containers int `json:"min_boxes"`
+	}
+	errBroken := decodeBody(newSyntheticBody("PUT", []byte("min_boxes=two")), &broken)
+
+	// Then
+	if errDep != nil || dep.Stack.Name != "pinky" {
+		t.Errorf(msgFail, "DryRun synthetic stack", "pinky", fmt.Sprint(dep, errDep))
+	}
+	if errAddon != nil || addon.Settings["MYSQL_SIZE"] != "2" {
+		t.Errorf(msgFail, "DryRun synthetic settings", settings, fmt.Sprint(addon, errAddon))
+	}
+	if errBroken == nil {
+		t.Errorf(msgFail, "DryRun synthetic decode error", "error", errBroken)
+	}
+}
+
+func TestDryRunTokenAndDisable(t *testing.T) {
+	// Given
+	var paths []string
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.Method+" "+r.URL.Path)
+		if r.URL.Path == "/token/" {
+			fmt.Fprint(w, `{"token": "0987654321", "expires": "2222-3333"}`)
+		}
+	})
+	defer server.Close()
+
+	plan := api.EnableDryRun()
+
+	// When
+	errToken := api.CreateToken("john@example.com", "secret")
+	errDryRun := api.DeleteApplication("blog")
+	api.DisableDryRun()
+	errDelete := api.DeleteApplication("blog")
+
+	// Then
+	if errToken != nil || errDryRun != nil || errDelete != nil {
+		t.Fatalf(msgFail, "DryRun", nil, fmt.Sprint(errToken, errDryRun, errDelete))
+	}
+	if expected := []string{"POST /token/", "DELETE /app/blog/"}; !reflect.DeepEqual(paths, expected) {
+		t.Errorf(msgFail, "DryRun sent requests", expected, paths)
+	}
+	if api.Plan() != nil {
+		t.Errorf(msgFail, "DisableDryRun", nil, api.Plan())
+	}
+	if len(plan.Calls()) != 1 {
+		t.Errorf(msgFail, "Plan.Calls", 1, len(plan.Calls()))
+	}
+}
+
+func TestPlanWrite(t *testing.T) {
+	// Given
+	plan := &Plan{}
+	plan.record("CreateApplication", "post", "/app/", []byte("name=blog&type=php"))
+	plan.record("DeleteApplication", "DELETE", "/app/old/", nil)
+
+	// When
+	var buf bytes.Buffer
+	err := plan.Write(&buf)
+
+	// Then
+	expected := "POST /app/ name=blog&type=php (CreateApplication)\n" +
+		"DELETE /app/old/ (DeleteApplication)\n"
+	if err != nil || buf.String() != expected {
+		t.Errorf(msgFail, "Plan.Write", expected, buf.String())
+	}
+
+	plan.Reset()
+	if plan.String() != "" {
+		t.Errorf(msgFail, "Plan.Reset", "", plan.String())
+	}
+}
@@ -0,0 +1,443 @@
+package cclib
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	// scrubbedHeaders are request headers carrying credentials
+	scrubbedHeaders = []string{"Authorization", "Cookie"}
+	// scrubbedFields are the API fields carrying secrets
+	scrubbedFields = map[string]bool{
+		"password":      true,
+		"token":         true,
+		"sso_salt":      true,
+		"secret":        true,
+		"api_key":       true,
+		"access_key":    true,
+		"secret_key":    true,
+		"private_key":   true,
+		"client_secret": true,
+	}
+	// recordedHeaders are the response headers kept in fixtures
+	recordedHeaders = []string{"Content-Type", "Location"}
+)
+
+// Fixture contains a recorded request/response pair
+type Fixture struct {
+	Request  FixtureRequest  `json:"request"`
+	Response FixtureResponse `json:"response"`
+}
+
+// FixtureRequest contains the recorded request, with
+// its credentials scrubbed
+type FixtureRequest struct {
+	Method string      `json:"method"`
+	Path   string      `json:"path"`
+	Query  string      `json:"query,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// FixtureResponse contains the recorded response, with
+// its secrets scrubbed and its content decoded
+type FixtureResponse struct {
+	Status int         `json:"status"`
+	Header http.Header `json:"header,omitempty"`
+	Body   string      `json:"body,omitempty"`
+}
+
+// ReadFixtures reads a fixtures file written by a Recorder.
+//
+// Returns the fixtures and an error if file
+// can't be read or decoded.
+func ReadFixtures(path string) ([]Fixture, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var fixtures []Fixture
+	if err = decodeJSON(f, &fixtures); err != nil {
+		return nil, err
+	}
+	return fixtures, nil
+}
+
+// WriteFixtures writes fixtures to a file as indented JSON
+func WriteFixtures(path string, fixtures []Fixture) error {
+	content, err := json.MarshalIndent(fixtures, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(content, '\n'), 0644)
+}
+
+// Recorder is an http.RoundTripper which sends requests
+// through Next and records every request/response pair,
+// so they can be saved as fixtures for a Replayer:
+//
+//	recorder := cc.NewRecorder(nil)
+//	api.SetTransport(recorder)
+//	...
+//	err := recorder.Save("testdata/deploy.json")
+type Recorder struct {
+	// Next sends the requests. If nil, API requests are sent
+	// like without a Recorder, honouring SslCheck and CaCerts,
+	// and other requests through http.DefaultTransport.
+	Next http.RoundTripper
+
+	mu       sync.Mutex
+	fixtures []Fixture
+}
+
+// NewRecorder creates a Recorder sending requests through next.
+//
+// Returns a new Recorder pointer
+func NewRecorder(next http.RoundTripper) *Recorder {
+	return &Recorder{Next: next}
+}
+
+// RoundTrip sends a request and records it with its response.
+// The response content is decoded so fixtures are readable.
+func (recorder *Recorder) RoundTrip(r *http.Request) (*http.Response, error) {
+	data, err := readRequestBody(r)
+	if err != nil {
+		return nil, err
+	}
+
+	next := recorder.Next
+	if next == nil {
+		next, _ = r.Context().Value(defaultTransportKey{}).(http.RoundTripper)
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	resp, err := next.RoundTrip(r)
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := decodeContentEncoding(resp)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	content, err := ioutil.ReadAll(body)
+	body.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = int64(len(content))
+	resp.Body = ioutil.NopCloser(bytes.NewReader(content))
+
+	fixture := Fixture{
+		Request:  newFixtureRequest(r, data),
+		Response: newFixtureResponse(resp, content),
+	}
+
+	recorder.mu.Lock()
+	recorder.fixtures = append(recorder.fixtures, fixture)
+	recorder.mu.Unlock()
+
+	return resp, nil
+}
+
+// Fixtures returns the fixtures recorded so far
+func (recorder *Recorder) Fixtures() []Fixture {
+	recorder.mu.Lock()
+	defer recorder.mu.Unlock()
+	return append([]Fixture{}, recorder.fixtures...)
+}
+
+// Save writes the recorded fixtures to a file
+func (recorder *Recorder) Save(path string) error {
+	return WriteFixtures(path, recorder.Fixtures())
+}
+
+// Replayer is an http.RoundTripper serving recorded fixtures
+// instead of sending requests, so code using cclib can be
+// tested without network:
+//
+//	replayer, err := cc.NewReplayerFromFile("testdata/deploy.json")
+//	api.SetTransport(replayer)
+//
+// Requests match fixtures on method, path, query and body.
+// Identical requests are served in the recorded order, the
+// last matching fixture being served once all were used.
+type Replayer struct {
+	mu       sync.Mutex
+	fixtures []Fixture
+	used     []bool
+}
+
+// ReplayError is returned when no fixture matches a request
+type ReplayError struct {
+	Method string
+	Path   string
+}
+
+func (e *ReplayError) Error() string {
+	return fmt.Sprintf("No fixture matches %s %s.", e.Method, e.Path)
+}
+
+// NewReplayer creates a Replayer serving fixtures.
+//
+// Returns a new Replayer pointer
+func NewReplayer(fixtures []Fixture) *Replayer {
+	return &Replayer{
+		fixtures: fixtures,
+		used:     make([]bool, len(fixtures)),
+	}
+}
+
+// NewReplayerFromFile creates a Replayer serving
+// the fixtures of a file written by a Recorder.
+//
+// Returns a new Replayer pointer and an error
+// if fixtures can't be read.
+func NewReplayerFromFile(path string) (*Replayer, error) {
+	fixtures, err := ReadFixtures(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayer(fixtures), nil
+}
+
+// RoundTrip serves the fixture matching a request
+func (replayer *Replayer) RoundTrip(r *http.Request) (*http.Response, error) {
+	data, err := readRequestBody(r)
+	if err != nil {
+		return nil, err
+	}
+	request := newFixtureRequest(r, data)
+
+	replayer.mu.Lock()
+	defer replayer.mu.Unlock()
+
+	match := -1
+	for i, fixture := range replayer.fixtures {
+		if !fixture.Request.matches(request) {
+			continue
+		}
+		match = i
+		if !replayer.used[i] {
+			break
+		}
+	}
+
+	if match < 0 {
+		return nil, &ReplayError{Method: request.Method, Path: request.Path}
+	}
+	replayer.used[match] = true
+
+	return replayer.fixtures[match].Response.response(r), nil
+}
+
+// Unused returns the fixtures which were never served,
+// useful to check that recorded calls are still made
+func (replayer *Replayer) Unused() []Fixture {
+	replayer.mu.Lock()
+	defer replayer.mu.Unlock()
+
+	var unused []Fixture
+	for i, fixture := range replayer.fixtures {
+		if !replayer.used[i] {
+			unused = append(unused, fixture)
+		}
+	}
+	return unused
+}
+
+func (fr FixtureRequest) matches(other FixtureRequest) bool {
+	return strings.EqualFold(fr.Method, other.Method) &&
+		fr.Path == other.Path &&
+		canonicalQuery(fr.Query) == canonicalQuery(other.Query) &&
+		canonicalBody(fr.Body) == canonicalBody(other.Body)
+}
+
+func (fr FixtureResponse) response(r *http.Request) *http.Response {
+	header := http.Header{}
+	for key, values := range fr.Header {
+		header[key] = append([]string{}, values...)
+	}
+
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", fr.Status, strings.ToUpper(http.StatusText(fr.Status))),
+		StatusCode:    fr.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(strings.NewReader(fr.Body)),
+		ContentLength: int64(len(fr.Body)),
+		Request:       r,
+	}
+}
+
+// readRequestBody reads the body of a request
+// and restores it so it can be sent
+func readRequestBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, nil
+	}
+
+	data, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+func newFixtureRequest(r *http.Request, data []byte) FixtureRequest {
+	header := http.Header{}
+	for _, key := range scrubbedHeaders {
+		if r.Header.Get(key) != "" {
+			header.Set(key, MaskedValue)
+		}
+	}
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	if len(header) == 0 {
+		header = nil
+	}
+
+	return FixtureRequest{
+		Method: r.Method,
+		Path:   r.URL.Path,
+		Query:  scrubForm(r.URL.RawQuery),
+		Header: header,
+		Body:   scrubBody(data),
+	}
+}
+
+func newFixtureResponse(resp *http.Response, content []byte) FixtureResponse {
+	header := http.Header{}
+	for _, key := range recordedHeaders {
+		if value := resp.Header.Get(key); value != "" {
+			header.Set(key, value)
+		}
+	}
+	if len(header) == 0 {
+		header = nil
+	}
+
+	return FixtureResponse{
+		Status: resp.StatusCode,
+		Header: header,
+		Body:   scrubBody(content),
+	}
+}
+
+// scrubBody scrubs the secrets of a JSON or form encoded body
+func scrubBody(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+
+	if isJSONData(data) {
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err == nil {
+			scrubbed, _ := json.Marshal(scrubJSON(v))
+			return string(scrubbed)
+		}
+	}
+
+	return scrubForm(string(data))
+}
+
+// scrubForm scrubs the secrets of a form encoded string,
+// which is returned as it is if it can't be parsed
+func scrubForm(form string) string {
+	values, err := url.ParseQuery(form)
+	if err != nil || form == "" || !strings.Contains(form, "=") {
+		return form
+	}
+
+	for key, vs := range values {
+		for i, v := range vs {
+			if isScrubbedField(key) {
+				vs[i] = MaskedValue
+			} else {
+				vs[i] = maskSecretURL(v)
+			}
+		}
+	}
+	return values.Encode()
+}
+
+func scrubJSON(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for key, item := range value {
+			if _, ok := item.(string); ok && isScrubbedField(key) {
+				value[key] = MaskedValue
+			} else {
+				value[key] = scrubJSON(item)
+			}
+		}
+	case []interface{}:
+		for i, item := range value {
+			value[i] = scrubJSON(item)
+		}
+	case string:
+		return maskSecretURL(value)
+	}
+	return v
+}
+
+// maskSecretURL masks the password of a URL, if any
+func maskSecretURL(value string) string {
+	if !strings.Contains(value, "://") {
+		return value
+	}
+	return maskURL(value)
+}
+
+// isScrubbedField returns true if a field is secret: one of
+// scrubbedFields or an add-on setting, whose names are upper
+// cased, like MYSQL_PASSWORD or AWS_SECRET_ACCESS_KEY
+func isScrubbedField(key string) bool {
+	if scrubbedFields[strings.ToLower(key)] {
+		return true
+	}
+	return key == strings.ToUpper(key) && isSecretKey(key)
+}
+
+// canonicalQuery sorts the values of a query
+func canonicalQuery(query string) string {
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		return query
+	}
+	return values.Encode()
+}
+
+// canonicalBody returns a body in a form which does
+// not depend on its keys order
+func canonicalBody(body string) string {
+	if isJSONData([]byte(body)) {
+		var v interface{}
+		if err := json.Unmarshal([]byte(body), &v); err == nil {
+			canonical, _ := json.Marshal(v)
+			return string(canonical)
+		}
+	}
+	return canonicalQuery(body)
+}
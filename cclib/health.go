@@ -0,0 +1,320 @@
+package cclib
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Health statuses of a HealthResult
+const (
+	HealthOK     = "ok"
+	HealthSlow   = "slow"
+	HealthFailed = "failed"
+)
+
+// Kinds of the URLs probed by a HealthChecker
+const (
+	HealthDefaultSubdomain = "default_subdomain"
+	HealthAlias            = "alias"
+)
+
+// HealthCheck defines how a URL is probed
+type HealthCheck struct {
+	// Scheme of the probed URLs, https if empty
+	Scheme string
+	// Path requested on every host, / if empty
+	Path string
+	// ExpectedStatus of the responses, any 2xx if 0
+	ExpectedStatus int
+	// InsecureSkipVerify disables the TLS certificates validation
+	InsecureSkipVerify bool
+	// Timeout of each probe, 10 seconds if 0
+	Timeout time.Duration
+	// SlowLatency marks slower responses as slow, disabled if 0
+	SlowLatency time.Duration
+	// MaxLatency marks slower responses as failed, disabled if 0
+	MaxLatency time.Duration
+}
+
+// HealthResult contains the result of probing a URL
+type HealthResult struct {
+	App        string `json:"app"`
+	Deployment string `json:"deployment"`
+	// Kind is HealthDefaultSubdomain or HealthAlias
+	Kind       string        `json:"kind"`
+	URL        string        `json:"url"`
+	Status     string        `json:"status"`
+	StatusCode int           `json:"status_code,omitempty"`
+	Latency    time.Duration `json:"latency"`
+	Error      string        `json:"error,omitempty"`
+}
+
+// HealthReport contains the results of a health check
+type HealthReport struct {
+	Checked time.Time      `json:"checked"`
+	Results []HealthResult `json:"results"`
+	// Errors contains the resources that could not be read
+	Errors []ResourceError `json:"-"`
+}
+
+// HealthError is returned by HealthReport.Err
+// when some URLs failed their check
+type HealthError struct {
+	Failed []HealthResult
+}
+
+func (e *HealthError) Error() string {
+	urls := make([]string, len(e.Failed))
+	for i, result := range e.Failed {
+		urls[i] = result.URL
+		if result.Error != "" {
+			urls[i] += " (" + result.Error + ")"
+		}
+	}
+	return fmt.Sprintf("%d URLs are unhealthy: %s.", len(e.Failed), strings.Join(urls, ", "))
+}
+
+// Failed returns the failed results
+func (report *HealthReport) Failed() []HealthResult {
+	var failed []HealthResult
+	for _, result := range report.Results {
+		if result.Status == HealthFailed {
+			failed = append(failed, result)
+		}
+	}
+	return failed
+}
+
+// Healthy returns true if no URL failed and
+// every resource could be read
+func (report *HealthReport) Healthy() bool {
+	return len(report.Failed()) == 0 && len(report.Errors) == 0
+}
+
+// Err returns a *HealthError if some URLs failed, a
+// *BatchError if resources could not be read or nil,
+// so a report can be used as a deploy gate
+func (report *HealthReport) Err() error {
+	if failed := report.Failed(); len(failed) > 0 {
+		return &HealthError{failed}
+	}
+	if len(report.Errors) > 0 {
+		return &BatchError{report.Errors}
+	}
+	return nil
+}
+
+// WriteJSON writes the report as indented JSON
+func (report *HealthReport) WriteJSON(w io.Writer) error {
+	content, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(append(content, '\n'))
+	return err
+}
+
+// HealthChecker probes the default subdomains and
+// the verified aliases of applications
+type HealthChecker struct {
+	Api   *API
+	Check HealthCheck
+	// Concurrency is the number of URLs probed at the same time
+	Concurrency int
+}
+
+// NewHealthChecker creates a HealthChecker for an API
+// with DefaultConcurrency.
+//
+// Returns a new HealthChecker pointer
+func NewHealthChecker(api *API, check HealthCheck) *HealthChecker {
+	return &HealthChecker{Api: api, Check: check, Concurrency: DefaultConcurrency}
+}
+
+// Run probes the deployments matching patterns, every
+// deployment if none. eg: `shop-*` matches application
+// names and `shop-*/prod*` application/deployment names.
+// See path.Match for the syntax.
+//
+// Returns the HealthReport and an error if
+// the applications can not be read.
+func (checker *HealthChecker) Run(patterns ...string) (*HealthReport, error) {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	apps, err := checker.Api.ReadApplications()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &HealthReport{Checked: time.Now().UTC()}
+	var targets []HealthResult
+	for _, app := range *apps {
+		if !matchesAnyApp(patterns, app.Name) {
+			continue
+		}
+
+		deployments, err := checker.Api.ReadDeployments(app.Name)
+		if err != nil {
+			report.Errors = append(report.Errors, ResourceError{app.Name, "", "deployments", err})
+			continue
+		}
+
+		for _, dep := range *deployments {
+			depName := deploymentName(dep.Name)
+			if !matchesAnyDeployment(patterns, app.Name, depName) {
+				continue
+			}
+
+			if dep.DefaultSubdomain != "" {
+				targets = append(targets, checker.target(app.Name, depName, HealthDefaultSubdomain, dep.DefaultSubdomain))
+			}
+
+			aliases, err := checker.Api.ReadAliases(app.Name, depName)
+			if err != nil {
+				report.Errors = append(report.Errors, ResourceError{app.Name, depName, "aliases", err})
+				continue
+			}
+			for _, alias := range *aliases {
+				if alias.IsVerified && !alias.IsDefault {
+					targets = append(targets, checker.target(app.Name, depName, HealthAlias, alias.Name))
+				}
+			}
+		}
+	}
+
+	report.Results = checker.probeAll(targets)
+	return report, nil
+}
+
+// DeploymentProbe returns a DeploymentProbe checking the default
+// subdomain of a deployment, eg: to be used by a Promoter
+func (checker *HealthChecker) DeploymentProbe() DeploymentProbe {
+	return func(deployment *Deployment) error {
+		appName := strings.SplitN(deployment.Name, "/", 2)[0]
+		result := checker.target(appName, deploymentName(deployment.Name), HealthDefaultSubdomain, deployment.DefaultSubdomain)
+		checker.probe(checker.client(), &result)
+		if result.Status == HealthFailed {
+			return &HealthError{[]HealthResult{result}}
+		}
+		return nil
+	}
+}
+
+func (checker *HealthChecker) target(appName, depName, kind, host string) HealthResult {
+	scheme := checker.Check.Scheme
+	if scheme == "" {
+		scheme = "https"
+	}
+	return HealthResult{
+		App:        appName,
+		Deployment: depName,
+		Kind:       kind,
+		URL:        scheme + "://" + host + "/" + strings.TrimPrefix(checker.Check.Path, "/"),
+	}
+}
+
+func (checker *HealthChecker) client() *http.Client {
+	timeout := checker.Check.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: checker.Check.InsecureSkipVerify},
+		},
+	}
+}
+
+func (checker *HealthChecker) probeAll(targets []HealthResult) []HealthResult {
+	concurrency := checker.Concurrency
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	client := checker.client()
+	sem := make(chan bool, concurrency)
+	var wg sync.WaitGroup
+	for i := range targets {
+		wg.Add(1)
+		go func(result *HealthResult) {
+			defer wg.Done()
+			sem <- true
+			defer func() { <-sem }()
+			checker.probe(client, result)
+		}(&targets[i])
+	}
+	wg.Wait()
+
+	sort.SliceStable(targets, func(i, j int) bool {
+		return targets[i].URL < targets[j].URL
+	})
+	return targets
+}
+
+// probe requests the URL of result and sets its status
+func (checker *HealthChecker) probe(client *http.Client, result *HealthResult) {
+	start := time.Now()
+	resp, err := client.Get(result.URL)
+	result.Latency = time.Since(start)
+	if err != nil {
+		result.Status, result.Error = HealthFailed, err.Error()
+		return
+	}
+	resp.Body.Close()
+	result.StatusCode = resp.StatusCode
+
+	check := checker.Check
+	switch {
+	case check.ExpectedStatus == 0 && (resp.StatusCode < 200 || resp.StatusCode >= 300),
+		check.ExpectedStatus != 0 && resp.StatusCode != check.ExpectedStatus:
+		result.Status, result.Error = HealthFailed, "unexpected status "+resp.Status
+	case check.MaxLatency > 0 && result.Latency > check.MaxLatency:
+		result.Status, result.Error = HealthFailed, fmt.Sprintf("latency over %v", check.MaxLatency)
+	case check.SlowLatency > 0 && result.Latency > check.SlowLatency:
+		result.Status = HealthSlow
+	default:
+		result.Status = HealthOK
+	}
+}
+
+func matchesAnyApp(patterns []string, appName string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		appPattern, _ := splitAccessPattern(pattern)
+		if ok, _ := path.Match(appPattern, appName); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAnyDeployment(patterns []string, appName, depName string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		appPattern, depPattern := splitAccessPattern(pattern)
+		if ok, _ := path.Match(appPattern, appName); !ok {
+			continue
+		}
+		if ok, _ := path.Match(depPattern, depName); ok || depPattern == "" {
+			return true
+		}
+	}
+	return false
+}
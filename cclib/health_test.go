@@ -0,0 +1,154 @@
+package cclib
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newHealthServers() (ok, slow, broken *httptest.Server) {
+	ok = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	slow = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(30 * time.Millisecond)
+	}))
+	broken = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	return
+}
+
+func hostOf(server *httptest.Server) string {
+	return strings.TrimPrefix(strings.TrimPrefix(server.URL, "http://"), "https://")
+}
+
+func TestHealthCheckerRun(t *testing.T) {
+	// Given
+	ok, slow, broken := newHealthServers()
+	defer ok.Close()
+	defer slow.Close()
+	defer broken.Close()
+
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/app/":
+			fmt.Fprint(w, `[{"name": "blog"}, {"name": "shop"}]`)
+		case "/app/blog/deployment/":
+			fmt.Fprintf(w, `[{"name": "blog/default", "default_subdomain": "%s"}, {"name": "blog/dev", "default_subdomain": "%s"}]`,
+				hostOf(ok), hostOf(slow))
+		case "/app/blog/deployment/default/alias/":
+			fmt.Fprintf(w, `[{"name": "%s", "is_verified": true}, {"name": "pending.example.com"}, {"name": "blog.invalid", "is_default": true, "is_verified": true}]`,
+				hostOf(broken))
+		case "/app/blog/deployment/dev/alias/":
+			fmt.Fprint(w, `[]`)
+		default:
+			t.Errorf(msgFail, "HealthChecker.Run resources", "blog only", r.URL.Path)
+		}
+	})
+	defer server.Close()
+
+	checker := NewHealthChecker(api, HealthCheck{
+		Scheme:      "http",
+		Path:        "/health",
+		SlowLatency: 20 * time.Millisecond,
+		MaxLatency:  time.Second,
+	})
+
+	// When
+	report, err := checker.Run("blog")
+
+	// Then
+	if err != nil {
+		t.Fatalf(msgFail, "HealthChecker.Run", nil, err)
+	}
+	if len(report.Results) != 3 {
+		t.Fatalf(msgFail, "HealthChecker.Run results", 3, report.Results)
+	}
+
+	statuses := map[string]string{}
+	for _, result := range report.Results {
+		statuses[result.Deployment+" "+result.Kind] = result.Status
+	}
+	expected := map[string]string{
+		"default " + HealthDefaultSubdomain: HealthOK,
+		"default " + HealthAlias:            HealthFailed,
+		"dev " + HealthDefaultSubdomain:     HealthSlow,
+	}
+	for key, status := range expected {
+		if statuses[key] != status {
+			t.Errorf(msgFail, "HealthChecker.Run "+key, status, statuses[key])
+		}
+	}
+
+	if report.Healthy() {
+		t.Errorf(msgFail, "Healthy", false, true)
+	}
+	herr, isHealthErr := report.Err().(*HealthError)
+	if !isHealthErr || len(herr.Failed) != 1 || !strings.Contains(herr.Error(), "unexpected status 500") {
+		t.Errorf(msgFail, "Err", "1 failed URL", report.Err())
+	}
+
+	var buf bytes.Buffer
+	if err = report.WriteJSON(&buf); err != nil || !strings.Contains(buf.String(), `"status": "slow"`) {
+		t.Errorf(msgFail, "WriteJSON", `"status": "slow"`, buf.String())
+	}
+}
+
+func TestHealthCheckerExpectations(t *testing.T) {
+	// Given
+	ok, slow, broken := newHealthServers()
+	defer ok.Close()
+	defer slow.Close()
+	defer broken.Close()
+
+	checks := []struct {
+		check    HealthCheck
+		server   *httptest.Server
+		expected string
+	}{
+		{HealthCheck{Scheme: "http", Path: "/health"}, ok, HealthOK},
+		{HealthCheck{Scheme: "http", Path: "/missing"}, ok, HealthFailed},
+		{HealthCheck{Scheme: "http", Path: "/missing", ExpectedStatus: 404}, ok, HealthOK},
+		{HealthCheck{Scheme: "http", ExpectedStatus: 500}, broken, HealthOK},
+		{HealthCheck{Scheme: "http", MaxLatency: 10 * time.Millisecond}, slow, HealthFailed},
+		{HealthCheck{Scheme: "http", Timeout: 10 * time.Millisecond}, slow, HealthFailed},
+	}
+
+	for _, c := range checks {
+		// When
+		checker := NewHealthChecker(nil, c.check)
+		result := checker.target("app", "default", HealthDefaultSubdomain, hostOf(c.server))
+		checker.probe(checker.client(), &result)
+
+		// Then
+		if result.Status != c.expected {
+			t.Errorf(msgFail, "probe "+result.URL, c.expected, result.Status+" "+result.Error)
+		}
+	}
+}
+
+func TestHealthCheckerTLS(t *testing.T) {
+	// Given
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	deployment := &Deployment{Name: "app/default", DefaultSubdomain: hostOf(server)}
+
+	// When
+	errValidated := NewHealthChecker(nil, HealthCheck{}).DeploymentProbe()(deployment)
+	errInsecure := NewHealthChecker(nil, HealthCheck{InsecureSkipVerify: true}).DeploymentProbe()(deployment)
+
+	// Then
+	if errValidated == nil {
+		t.Errorf(msgFail, "DeploymentProbe with TLS validation", "error", errValidated)
+	}
+	if errInsecure != nil {
+		t.Errorf(msgFail, "DeploymentProbe without TLS validation", nil, errInsecure)
+	}
+}
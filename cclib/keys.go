@@ -0,0 +1,286 @@
+package cclib
+
+import (
+	"bufio"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultPublicKeys is the pattern of the
+// local public key files of the current user
+var DefaultPublicKeys = filepath.Join("~", ".ssh", "*.pub")
+
+var publicKeyTypes = map[string]bool{
+	"ssh-rsa":                            true,
+	"ssh-dss":                            true,
+	"ssh-ed25519":                        true,
+	"ecdsa-sha2-nistp256":                true,
+	"ecdsa-sha2-nistp384":                true,
+	"ecdsa-sha2-nistp521":                true,
+	"sk-ssh-ed25519@openssh.com":         true,
+	"sk-ecdsa-sha2-nistp256@openssh.com": true,
+}
+
+// PublicKey contains a parsed SSH public key
+type PublicKey struct {
+	Type    string
+	Blob    []byte
+	Comment string
+}
+
+// KeySync contains the result of synchronizing
+// the keys of a user
+type KeySync struct {
+	Created []Key
+	Deleted []Key
+	Kept    []Key
+	// Skipped contains remote keys whose material
+	// could not be parsed, they are never deleted
+	Skipped []Key
+}
+
+// ParsePublicKey parses and validates a public key
+// in authorized_keys format, with or without options:
+//
+//	[options] type base64-blob [comment]
+func ParsePublicKey(line string) (*PublicKey, error) {
+	fields := strings.Fields(line)
+	for i, field := range fields {
+		if !publicKeyTypes[field] {
+			continue
+		}
+		if i+1 >= len(fields) {
+			return nil, fmt.Errorf("Public key of type %s has no data.", field)
+		}
+
+		blob, err := base64.StdEncoding.DecodeString(fields[i+1])
+		if err != nil {
+			return nil, fmt.Errorf("Public key data is not valid base64: %v", err)
+		}
+
+		if blobType := publicKeyBlobType(blob); blobType != field {
+			return nil, fmt.Errorf("Public key type %s does not match its data type %q.", field, blobType)
+		}
+
+		return &PublicKey{
+			Type:    field,
+			Blob:    blob,
+			Comment: strings.Join(fields[i+2:], " "),
+		}, nil
+	}
+
+	return nil, errors.New("Public key type is missing or not supported.")
+}
+
+// publicKeyBlobType reads the key type encoded at the start of the blob
+func publicKeyBlobType(blob []byte) string {
+	if len(blob) < 4 {
+		return ""
+	}
+
+	n := binary.BigEndian.Uint32(blob)
+	if uint64(n) > uint64(len(blob)-4) {
+		return ""
+	}
+
+	return string(blob[4 : 4+n])
+}
+
+// String returns the public key in authorized_keys format
+func (key PublicKey) String() string {
+	s := key.Type + " " + base64.StdEncoding.EncodeToString(key.Blob)
+	if key.Comment != "" {
+		s += " " + key.Comment
+	}
+	return s
+}
+
+// FingerprintMD5 returns the legacy MD5 fingerprint,
+// eg: 16:bb:5e:28:...
+func (key PublicKey) FingerprintMD5() string {
+	sum := md5.Sum(key.Blob)
+	hex := make([]string, len(sum))
+	for i, b := range sum {
+		hex[i] = fmt.Sprintf("%02x", b)
+	}
+	return strings.Join(hex, ":")
+}
+
+// FingerprintSHA256 returns the SHA256 fingerprint,
+// eg: SHA256:g+I/ZexW...
+func (key PublicKey) FingerprintSHA256() string {
+	sum := sha256.Sum256(key.Blob)
+	return "SHA256:" + strings.TrimRight(base64.StdEncoding.EncodeToString(sum[:]), "=")
+}
+
+// ParsedKey parses the material of a user Key
+func (key Key) ParsedKey() (*PublicKey, error) {
+	return ParsePublicKey(key.PublicKey)
+}
+
+// ReadPublicKeyFile reads every public key of a .pub
+// or authorized_keys file given its path.
+// Empty lines and comments are ignored.
+func ReadPublicKeyFile(path string) ([]PublicKey, error) {
+	f, err := os.Open(expandHome(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var keys []PublicKey
+	scanner := bufio.NewScanner(f)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, err := ParsePublicKey(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNumber, err)
+		}
+		keys = append(keys, *key)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// ReadPublicKeyFiles reads the public keys of every file
+// matching a pattern, eg: DefaultPublicKeys
+func ReadPublicKeyFiles(pattern string) ([]PublicKey, error) {
+	paths, err := filepath.Glob(expandHome(pattern))
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []PublicKey
+	for _, path := range paths {
+		fileKeys, err := ReadPublicKeyFile(path)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, fileKeys...)
+	}
+
+	return keys, nil
+}
+
+// CreateUserKeysFromFile uploads the keys of a file having:
+//
+// * User name
+//
+// * Path of a .pub or authorized_keys file
+//
+// Keys are validated locally before any of them is uploaded.
+//
+// Returns the just created Keys
+// and an error if any request does not success.
+func (api *API) CreateUserKeysFromFile(userName, path string) (_ []Key, err error) {
+	op := api.begin("CreateUserKeysFromFile")
+	defer func() { op.end(err) }()
+	api = api.within(op)
+
+	keys, err := ReadPublicKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var created []Key
+	for _, key := range keys {
+		k, err := api.CreateUserKey(userName, key.String())
+		if err != nil {
+			return created, err
+		}
+		created = append(created, *k)
+	}
+
+	return created, nil
+}
+
+// SyncUserKeys makes the keys of a user match a local set having:
+//
+// * User name
+//
+// * List of desired public keys, eg: from ReadPublicKeyFiles
+//
+// Keys are compared by SHA256 fingerprint. Missing keys are created
+// before the ones not present in the desired list are deleted.
+//
+// Returns a KeySync with the applied changes
+// and an error if any request does not success. In that case
+// the KeySync contains the changes applied so far.
+func (api *API) SyncUserKeys(userName string, desired []PublicKey) (_ *KeySync, err error) {
+	op := api.begin("SyncUserKeys")
+	defer func() { op.end(err) }()
+	api = api.within(op)
+
+	existing, err := api.ReadUserKeys(userName)
+	if err != nil {
+		return nil, err
+	}
+
+	sync := &KeySync{}
+	wanted := map[string]bool{}
+	for _, key := range desired {
+		wanted[key.FingerprintSHA256()] = true
+	}
+
+	present := map[string]bool{}
+	var remaining []Key
+	for _, key := range *existing {
+		parsed, err := key.ParsedKey()
+		switch {
+		case err != nil:
+			sync.Skipped = append(sync.Skipped, key)
+		case wanted[parsed.FingerprintSHA256()] && !present[parsed.FingerprintSHA256()]:
+			present[parsed.FingerprintSHA256()] = true
+			sync.Kept = append(sync.Kept, key)
+		default:
+			remaining = append(remaining, key)
+		}
+	}
+
+	for _, key := range desired {
+		fingerprint := key.FingerprintSHA256()
+		if present[fingerprint] {
+			continue
+		}
+		created, err := api.CreateUserKey(userName, key.String())
+		if err != nil {
+			return sync, err
+		}
+		present[fingerprint] = true
+		sync.Created = append(sync.Created, *created)
+	}
+
+	for _, key := range remaining {
+		if err := api.DeleteUserKey(userName, key.Id); err != nil {
+			return sync, err
+		}
+		sync.Deleted = append(sync.Deleted, key)
+	}
+
+	return sync, nil
+}
+
+// expandHome replaces a leading ~ with the user home directory
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~"+string(filepath.Separator)) {
+		return filepath.Join(os.Getenv("HOME"), path[1:])
+	}
+	return path
+}
@@ -0,0 +1,130 @@
+package cclib
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var (
+	testKeyEd25519 = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIFuPBQYUWPc1hWacTG0MawZwiu4j1rPo7MI2eKNqALjz john@laptop"
+	testKeyRSA     = "ssh-rsa AAAAB3NzaC1yc2EAAAADAQABAAAAgQDHeqmFCJXcwGA8/Rs19XWqeRIdBkvFBx29f9uWIKV8X6JmPJRAXOcZzfFmLwPNlstUCjtPngd/FWHX1S1s5W3SyJr0Cfn6nRQsVld831wRSE43FFRysg1feLXYYBTNJzaNoiTwN93yS7JKT/4HKCDph+vk2uMrxSL8f0yLdwDqaw== john@desktop"
+)
+
+func TestParsePublicKey(t *testing.T) {
+	// Given
+	withOptions := `command="echo hi",no-pty ` + testKeyEd25519
+	mismatch := "ssh-rsa AAAAC3NzaC1lZDI1NTE5AAAAIFuPBQYUWPc1hWacTG0MawZwiu4j1rPo7MI2eKNqALjz"
+
+	// When
+	key, err := ParsePublicKey(withOptions)
+	_, errMismatch := ParsePublicKey(mismatch)
+	_, errUnknown := ParsePublicKey("foo bar")
+
+	// Then
+	if err != nil {
+		t.Fatalf(msgFail, "ParsePublicKey", nil, err)
+	}
+	if key.Type != "ssh-ed25519" || key.Comment != "john@laptop" {
+		t.Errorf(msgFail, "ParsePublicKey", "ssh-ed25519 john@laptop", key)
+	}
+	if key.String() != testKeyEd25519 {
+		t.Errorf(msgFail, "PublicKey.String", testKeyEd25519, key.String())
+	}
+	expectedMismatch := `Public key type ssh-rsa does not match its data type "ssh-ed25519".`
+	if errMismatch == nil || errMismatch.Error() != expectedMismatch {
+		t.Errorf(msgFail, "ParsePublicKey", expectedMismatch, errMismatch)
+	}
+	expectedUnknown := "Public key type is missing or not supported."
+	if errUnknown == nil || errUnknown.Error() != expectedUnknown {
+		t.Errorf(msgFail, "ParsePublicKey", expectedUnknown, errUnknown)
+	}
+}
+
+func TestPublicKeyFingerprints(t *testing.T) {
+	// Given
+	key, _ := ParsePublicKey(testKeyEd25519)
+	expectedMD5 := "16:bb:5e:28:e4:5a:5c:b0:e2:39:7e:88:9d:c4:bc:ca"
+	expectedSHA256 := "SHA256:g+I/ZexW81RsYN5tmiyasXX9NES5wQJDyKRAWTC9Jfk"
+
+	// When
+	md5 := key.FingerprintMD5()
+	sha256 := key.FingerprintSHA256()
+
+	// Then
+	if md5 != expectedMD5 {
+		t.Errorf(msgFail, "FingerprintMD5", expectedMD5, md5)
+	}
+	if sha256 != expectedSHA256 {
+		t.Errorf(msgFail, "FingerprintSHA256", expectedSHA256, sha256)
+	}
+}
+
+func TestReadPublicKeyFile(t *testing.T) {
+	// Given
+	dir, err := ioutil.TempDir("", "cclib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	location := filepath.Join(dir, "authorized_keys")
+	ioutil.WriteFile(location, []byte("# keys\n"+testKeyEd25519+"\n\n"+testKeyRSA+"\n"), 0644)
+
+	// When
+	keys, err := ReadPublicKeyFile(location)
+
+	// Then
+	if err != nil {
+		t.Errorf(msgFail, "ReadPublicKeyFile", nil, err)
+	}
+	if len(keys) != 2 || keys[0].Type != "ssh-ed25519" || keys[1].Type != "ssh-rsa" {
+		t.Errorf(msgFail, "ReadPublicKeyFile", 2, keys)
+	}
+}
+
+func TestSyncUserKeys(t *testing.T) {
+	// Given
+	var created, deleted []string
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			fmt.Fprintf(w, `[
+				{"key_id": "key1", "key": %q},
+				{"key_id": "key2", "key": "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIHHFIc5NZYq4nSAXF63siXBU4wdo4orr2TuSMr+CQpB2 old@host"},
+				{"key_id": "key3", "key": ""}
+			]`, testKeyEd25519)
+		case "POST":
+			r.ParseForm()
+			created = append(created, r.PostForm.Get("key"))
+			fmt.Fprintf(w, `{"key_id": "key4", "key": %q}`, r.PostForm.Get("key"))
+		case "DELETE":
+			deleted = append(deleted, r.URL.Path)
+			w.WriteHeader(204)
+		}
+	})
+	defer server.Close()
+
+	ed25519, _ := ParsePublicKey(testKeyEd25519)
+	rsa, _ := ParsePublicKey(testKeyRSA)
+
+	// When
+	sync, err := api.SyncUserKeys("john", []PublicKey{*ed25519, *rsa})
+
+	// Then
+	if err != nil {
+		t.Fatalf(msgFail, "SyncUserKeys", nil, err)
+	}
+	if !reflect.DeepEqual(created, []string{testKeyRSA}) {
+		t.Errorf(msgFail, "SyncUserKeys and POST", testKeyRSA, created)
+	}
+	if !reflect.DeepEqual(deleted, []string{"/user/john/key/key2/"}) {
+		t.Errorf(msgFail, "SyncUserKeys and DELETE", "/user/john/key/key2/", deleted)
+	}
+	if len(sync.Kept) != 1 || len(sync.Skipped) != 1 || sync.Skipped[0].Id != "key3" {
+		t.Errorf(msgFail, "SyncUserKeys", "key1 kept and key3 skipped", sync)
+	}
+}
@@ -0,0 +1,197 @@
+package cclib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	manifestIdRegexp        = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+	manifestConfigVarRegexp = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+)
+
+// AddonManifest contains the description of an add-on
+// that a provider registers into the platform
+type AddonManifest struct {
+	// Id is the add-on name, lowercase letters, digits and dashes
+	Id    string           `json:"id"`
+	Api   AddonManifestApi `json:"api"`
+	Plans []AddonPlan      `json:"plans,omitempty"`
+}
+
+// AddonManifestApi contains the provider API configuration
+// of an add-on manifest
+type AddonManifestApi struct {
+	// ConfigVars are the variables the provider returns on provision,
+	// they must be prefixed by the upper cased add-on id
+	ConfigVars []string `json:"config_vars"`
+	// Password is used by the platform to authenticate
+	// against the provider
+	Password string `json:"password"`
+	// SsoSalt is used to generate the single sign-on tokens
+	SsoSalt    string                `json:"sso_salt,omitempty"`
+	Production AddonManifestEndpoint `json:"production"`
+	Test       AddonManifestEndpoint `json:"test"`
+}
+
+// AddonManifestEndpoint contains the URLs of a provider environment.
+// It is encoded as a plain string if only BaseUrl is set.
+type AddonManifestEndpoint struct {
+	BaseUrl string `json:"base_url"`
+	SsoUrl  string `json:"sso_url,omitempty"`
+}
+
+// AddonPlan contains information about an add-on plan
+type AddonPlan struct {
+	Id   string `json:"id"`
+	Name string `json:"name,omitempty"`
+}
+
+// ManifestError contains every problem found
+// while validating an add-on manifest
+type ManifestError struct {
+	Problems []string
+}
+
+func (e *ManifestError) Error() string {
+	return fmt.Sprintf("Invalid add-on manifest: %s.", strings.Join(e.Problems, "; "))
+}
+
+func (e *ManifestError) add(format string, a ...interface{}) {
+	e.Problems = append(e.Problems, fmt.Sprintf(format, a...))
+}
+
+// MarshalJSON encodes an endpoint as a string if it has
+// no SSO URL and as an object otherwise
+func (endpoint AddonManifestEndpoint) MarshalJSON() ([]byte, error) {
+	if endpoint.SsoUrl == "" {
+		return json.Marshal(endpoint.BaseUrl)
+	}
+
+	type plain AddonManifestEndpoint
+	return json.Marshal(plain(endpoint))
+}
+
+// UnmarshalJSON decodes an endpoint given either
+// as a string or as an object
+func (endpoint *AddonManifestEndpoint) UnmarshalJSON(b []byte) error {
+	var baseUrl string
+	if err := json.Unmarshal(b, &baseUrl); err == nil {
+		*endpoint = AddonManifestEndpoint{BaseUrl: baseUrl}
+		return nil
+	}
+
+	type plain AddonManifestEndpoint
+	var p plain
+	if err := json.Unmarshal(b, &p); err != nil {
+		return err
+	}
+
+	*endpoint = AddonManifestEndpoint(p)
+	return nil
+}
+
+// Decode decodes bytes into an add-on manifest
+func (manifest *AddonManifest) Decode(b []byte) error {
+	return json.Unmarshal(b, manifest)
+}
+
+// Encode encodes an add-on manifest into bytes
+func (manifest AddonManifest) Encode() ([]byte, error) {
+	return json.Marshal(manifest)
+}
+
+// Read reads an add-on manifest from a given path
+func (manifest *AddonManifest) Read(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	return manifest.Decode(b)
+}
+
+// Validate checks the add-on manifest locally.
+// Returns a *ManifestError listing every problem found
+// or nil if manifest is valid.
+func (manifest AddonManifest) Validate() error {
+	e := &ManifestError{}
+
+	if manifest.Id == "" {
+		e.add("id is required")
+	} else if !manifestIdRegexp.MatchString(manifest.Id) {
+		e.add("id %q must contain only lowercase letters, digits and dashes", manifest.Id)
+	}
+
+	api := manifest.Api
+	if api.Password == "" {
+		e.add("api.password is required")
+	}
+
+	if len(api.ConfigVars) == 0 {
+		e.add("api.config_vars must contain at least one variable")
+	}
+	prefix := strings.ToUpper(strings.Replace(manifest.Id, "-", "_", -1)) + "_"
+	seen := map[string]bool{}
+	for _, configVar := range api.ConfigVars {
+		switch {
+		case !manifestConfigVarRegexp.MatchString(configVar):
+			e.add("api.config_vars %q must contain only uppercase letters, digits and underscores", configVar)
+		case manifest.Id != "" && !strings.HasPrefix(configVar, prefix):
+			e.add("api.config_vars %q must start with %s", configVar, prefix)
+		}
+		if seen[configVar] {
+			e.add("api.config_vars %q is duplicated", configVar)
+		}
+		seen[configVar] = true
+	}
+
+	if api.Production.BaseUrl == "" {
+		e.add("api.production.base_url is required")
+	}
+	validateManifestUrl(e, "api.production.base_url", api.Production.BaseUrl, true)
+	validateManifestUrl(e, "api.production.sso_url", api.Production.SsoUrl, true)
+	validateManifestUrl(e, "api.test.base_url", api.Test.BaseUrl, false)
+	validateManifestUrl(e, "api.test.sso_url", api.Test.SsoUrl, false)
+
+	if (api.Production.SsoUrl != "" || api.Test.SsoUrl != "") && api.SsoSalt == "" {
+		e.add("api.sso_salt is required when a sso_url is set")
+	}
+
+	plans := map[string]bool{}
+	for i, plan := range manifest.Plans {
+		if plan.Id == "" {
+			e.add("plans[%d].id is required", i)
+			continue
+		}
+		if plans[plan.Id] {
+			e.add("plans[%d].id %q is duplicated", i, plan.Id)
+		}
+		plans[plan.Id] = true
+	}
+
+	if len(e.Problems) > 0 {
+		return e
+	}
+	return nil
+}
+
+func validateManifestUrl(e *ManifestError, field, rawurl string, requiresHTTPS bool) {
+	if rawurl == "" {
+		return
+	}
+
+	u, err := url.Parse(rawurl)
+	if err != nil || !u.IsAbs() || u.Host == "" {
+		e.add("%s %q is not an absolute URL", field, rawurl)
+		return
+	}
+
+	if requiresHTTPS && u.Scheme != "https" {
+		e.add("%s %q must use https", field, rawurl)
+	}
+}
@@ -0,0 +1,154 @@
+package cclib
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func validManifest() AddonManifest {
+	return AddonManifest{
+		Id: "my-addon",
+		Api: AddonManifestApi{
+			ConfigVars: []string{"MY_ADDON_URL"},
+			Password:   "secret",
+			SsoSalt:    "salt",
+			Production: AddonManifestEndpoint{
+				BaseUrl: "https://addon.example.com/cloudcontrol/resources",
+				SsoUrl:  "https://addon.example.com/sso/login",
+			},
+			Test: AddonManifestEndpoint{BaseUrl: "http://localhost:4567/"},
+		},
+		Plans: []AddonPlan{{Id: "free"}, {Id: "premium", Name: "Premium"}},
+	}
+}
+
+func TestAddonManifestDecode(t *testing.T) {
+	// Given
+	b := []byte(`{
+		"id": "my-addon",
+		"api": {
+			"config_vars": ["MY_ADDON_URL"],
+			"password": "secret",
+			"sso_salt": "salt",
+			"production": {
+				"base_url": "https://addon.example.com/cloudcontrol/resources",
+				"sso_url": "https://addon.example.com/sso/login"
+			},
+			"test": "http://localhost:4567/"
+		},
+		"plans": [{"id": "free"}, {"id": "premium", "name": "Premium"}]
+	}`)
+	expectedManifest := validManifest()
+
+	// When
+	var manifest AddonManifest
+	err := manifest.Decode(b)
+
+	// Then
+	if err != nil {
+		t.Errorf(msgFail, "Decode", nil, err)
+	}
+	if !reflect.DeepEqual(manifest, expectedManifest) {
+		t.Errorf(msgFail, "Decode", expectedManifest, manifest)
+	}
+}
+
+func TestAddonManifestEncode(t *testing.T) {
+	// Given
+	manifest := validManifest()
+
+	// When
+	b, err := manifest.Encode()
+	var decoded AddonManifest
+	decoded.Decode(b)
+
+	// Then
+	if err != nil {
+		t.Errorf(msgFail, "Encode", nil, err)
+	}
+	if !reflect.DeepEqual(decoded, manifest) {
+		t.Errorf(msgFail, "Encode", manifest, decoded)
+	}
+}
+
+func TestAddonManifestValidate(t *testing.T) {
+	// Given
+	valid := validManifest()
+	invalid := AddonManifest{
+		Id: "My_Addon",
+		Api: AddonManifestApi{
+			ConfigVars: []string{"OTHER_URL", "lower"},
+			Production: AddonManifestEndpoint{
+				BaseUrl: "http://addon.example.com/",
+				SsoUrl:  "/sso",
+			},
+		},
+		Plans: []AddonPlan{{Id: "free"}, {Id: "free"}, {}},
+	}
+	expectedProblems := []string{
+		`id "My_Addon" must contain only lowercase letters, digits and dashes`,
+		`api.password is required`,
+		`api.config_vars "OTHER_URL" must start with MY_ADDON_`,
+		`api.config_vars "lower" must contain only uppercase letters, digits and underscores`,
+		`api.production.base_url "http://addon.example.com/" must use https`,
+		`api.production.sso_url "/sso" is not an absolute URL`,
+		`api.sso_salt is required when a sso_url is set`,
+		`plans[1].id "free" is duplicated`,
+		`plans[2].id is required`,
+	}
+
+	// When
+	errValid := valid.Validate()
+	errInvalid := invalid.Validate()
+
+	// Then
+	if errValid != nil {
+		t.Errorf(msgFail, "Validate", nil, errValid)
+	}
+	manifestErr, ok := errInvalid.(*ManifestError)
+	if !ok {
+		t.Fatalf(msgFail, "Validate", "*ManifestError", errInvalid)
+	}
+	if !reflect.DeepEqual(manifestErr.Problems, expectedProblems) {
+		t.Errorf(msgFail, "Validate", expectedProblems, manifestErr.Problems)
+	}
+}
+
+func TestRegisterAddonManifest(t *testing.T) {
+	// Given
+	var body []byte
+	var contentType string
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		contentType = r.Header.Get("Content-Type")
+		fmt.Fprint(w, `{"name": "my-addon"}`)
+	})
+	defer server.Close()
+	manifest := validManifest()
+
+	// When
+	addon, err := api.RegisterAddonManifest("provider@example.com", "password", &manifest)
+	_, errInvalid := api.RegisterAddonManifest("provider@example.com", "password", &AddonManifest{})
+
+	// Then
+	if err != nil {
+		t.Errorf(msgFail, "RegisterAddonManifest", nil, err)
+	}
+	if addon == nil || addon.Name != "my-addon" {
+		t.Errorf(msgFail, "RegisterAddonManifest", "my-addon", addon)
+	}
+	if contentType != "application/json" {
+		t.Errorf(msgFail, "RegisterAddonManifest and Content-Type", "application/json", contentType)
+	}
+	var sent AddonManifest
+	sent.Decode(body)
+	if !reflect.DeepEqual(sent, manifest) {
+		t.Errorf(msgFail, "RegisterAddonManifest and body", manifest, sent)
+	}
+	if errInvalid == nil {
+		t.Errorf(msgFail, "RegisterAddonManifest", "error", errInvalid)
+	}
+}
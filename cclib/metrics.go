@@ -0,0 +1,254 @@
+package cclib
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Collector is an interface that defines how API metrics
+// are collected, so they can be exported to any monitoring
+// system, eg: a Prometheus adapter.
+type Collector interface {
+	// ObserveRequest is called once per API call, after
+	// its last HTTP attempt
+	ObserveRequest(metric RequestMetric)
+
+	// ObserveTokenRefresh is called every time the API token
+	// is created or its expiration is refreshed
+	ObserveTokenRefresh(err error)
+}
+
+// MetricLabels identify a request metric
+type MetricLabels struct {
+	// API method, eg: ReadDeployment
+	Operation string
+	// HTTP method, eg: GET
+	Method string
+	// Resource template, eg: /app/{app}/deployment/{dep}/
+	Resource string
+	// Response status class, eg: 2xx, or error if
+	// no response was received
+	Status string
+}
+
+// RequestMetric contains the metrics of an API call
+type RequestMetric struct {
+	Labels MetricLabels
+	// Time until the response headers were received
+	Duration time.Duration
+	// HTTP attempts after the first one
+	Retries int
+}
+
+// DefaultLatencyBuckets are the upper bounds in seconds
+// of the latency histograms of a MemoryCollector
+var DefaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Histogram contains cumulative bucket counts, the total
+// count and the sum of observed values
+type Histogram struct {
+	Buckets []float64
+	Counts  []int
+	Count   int
+	Sum     float64
+}
+
+// NewHistogram creates an empty Histogram with the given
+// bucket upper bounds.
+//
+// Returns a new Histogram pointer
+func NewHistogram(buckets []float64) *Histogram {
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+	return &Histogram{Buckets: buckets, Counts: make([]int, len(buckets))}
+}
+
+// Observe adds a value to the histogram
+func (h *Histogram) Observe(value float64) {
+	for i, bound := range h.Buckets {
+		if value <= bound {
+			h.Counts[i]++
+		}
+	}
+	h.Count++
+	h.Sum += value
+}
+
+func (h *Histogram) copy() Histogram {
+	return Histogram{
+		Buckets: append([]float64{}, h.Buckets...),
+		Counts:  append([]int{}, h.Counts...),
+		Count:   h.Count,
+		Sum:     h.Sum,
+	}
+}
+
+// MemoryCollector is an in-memory Collector, useful
+// to test code using metrics or to export them later
+type MemoryCollector struct {
+	mu                 sync.Mutex
+	buckets            []float64
+	requests           map[MetricLabels]int
+	retries            map[MetricLabels]int
+	latency            map[MetricLabels]*Histogram
+	tokenRefreshes     int
+	tokenRefreshErrors int
+}
+
+// NewMemoryCollector creates an empty MemoryCollector
+// using DefaultLatencyBuckets.
+//
+// Returns a new MemoryCollector pointer
+func NewMemoryCollector() *MemoryCollector {
+	return &MemoryCollector{
+		buckets:  DefaultLatencyBuckets,
+		requests: map[MetricLabels]int{},
+		retries:  map[MetricLabels]int{},
+		latency:  map[MetricLabels]*Histogram{},
+	}
+}
+
+// ObserveRequest counts a request and its retries
+// and adds its duration to the latency histogram
+func (c *MemoryCollector) ObserveRequest(metric RequestMetric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.requests[metric.Labels]++
+	c.retries[metric.Labels] += metric.Retries
+
+	h, ok := c.latency[metric.Labels]
+	if !ok {
+		h = NewHistogram(c.buckets)
+		c.latency[metric.Labels] = h
+	}
+	h.Observe(metric.Duration.Seconds())
+}
+
+// ObserveTokenRefresh counts a token refresh,
+// and an error if it failed
+func (c *MemoryCollector) ObserveTokenRefresh(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.tokenRefreshes++
+	if err != nil {
+		c.tokenRefreshErrors++
+	}
+}
+
+// Labels returns the labels of every observed request, sorted
+func (c *MemoryCollector) Labels() []MetricLabels {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	labels := make([]MetricLabels, 0, len(c.requests))
+	for l := range c.requests {
+		labels = append(labels, l)
+	}
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].String() < labels[j].String()
+	})
+	return labels
+}
+
+// Requests returns the number of requests observed with labels
+func (c *MemoryCollector) Requests(labels MetricLabels) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.requests[labels]
+}
+
+// Retries returns the number of retries observed with labels
+func (c *MemoryCollector) Retries(labels MetricLabels) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.retries[labels]
+}
+
+// Latency returns a copy of the latency histogram of
+// the requests observed with labels
+func (c *MemoryCollector) Latency(labels MetricLabels) Histogram {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if h, ok := c.latency[labels]; ok {
+		return h.copy()
+	}
+	return NewHistogram(c.buckets).copy()
+}
+
+// TokenRefreshes returns the number of token refreshes
+// and how many of them failed
+func (c *MemoryCollector) TokenRefreshes() (total int, errors int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tokenRefreshes, c.tokenRefreshErrors
+}
+
+// String returns the labels in a Prometheus-like form
+func (l MetricLabels) String() string {
+	return `{operation="` + l.Operation + `",method="` + l.Method +
+		`",resource="` + l.Resource + `",status="` + l.Status + `"}`
+}
+
+// SetCollector sets the Collector of the API metrics
+func (api *API) SetCollector(collector Collector) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.collector = collector
+}
+
+// Collector returns the API metrics Collector
+func (api *API) Collector() Collector {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	return api.collector
+}
+
+// observeTokenRefresh passes a token refresh
+// to the API Collector, if any
+func (api *API) observeTokenRefresh(err error) {
+	if collector := api.Collector(); collector != nil {
+		collector.ObserveTokenRefresh(err)
+	}
+}
+
+// resourceParams are the placeholders of the names
+// following each collection of a resource path
+var resourceParams = map[string]string{
+	"app":        "{app}",
+	"deployment": "{dep}",
+	"addon":      "{addon}",
+	"alias":      "{alias}",
+	"cron":       "{cron}",
+	"log":        "{type}",
+	"user":       "{user}",
+	"worker":     "{worker}",
+	"key":        "{key}",
+	"billing":    "{billing}",
+}
+
+// resourceTemplate returns the template of a resource, so
+// metrics do not grow with every name. Resources alternate
+// collections and names, and a name is replaced by the
+// placeholder of its collection. The query, if any, is left
+// out. eg: /app/blog/deployment/default/ is
+// /app/{app}/deployment/{dep}/
+//
+// Actions on a collection, as /user/activation/, share the
+// template of its names; their operation tells them apart.
+func resourceTemplate(resource string) string {
+	if i := strings.Index(resource, "?"); i >= 0 {
+		resource = resource[:i]
+	}
+
+	parts := strings.Split(resource, "/")
+	for i := 2; i < len(parts); i += 2 {
+		if param, ok := resourceParams[parts[i-1]]; ok && parts[i] != "" {
+			parts[i] = param
+		}
+	}
+	return strings.Join(parts, "/")
+}
@@ -0,0 +1,166 @@
+package cclib
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestResourceTemplate(t *testing.T) {
+	resources := map[string]string{
+		"/app/":                                  "/app/",
+		"/app/blog/":                             "/app/{app}/",
+		"/app/blog/deployment/default/":          "/app/{app}/deployment/{dep}/",
+		"/app/blog/deployment/default/addon/":    "/app/{app}/deployment/{dep}/addon/",
+		"/app/blog/deployment/dev/addon/mysql/":  "/app/{app}/deployment/{dep}/addon/{addon}/",
+		"/app/blog/deployment/dev/log/error/":    "/app/{app}/deployment/{dep}/log/{type}/",
+		"/app/deployment/deployment/deployment/": "/app/{app}/deployment/{dep}/",
+		"/user/john/key/ab12/":                   "/user/{user}/key/{key}/",
+		"/user/activation/":                      "/user/{user}/",
+		"/token/":                                "/token/",
+	}
+
+	for resource, expected := range resources {
+		if template := resourceTemplate(resource); template != expected {
+			t.Errorf(msgFail, "resourceTemplate "+resource, expected, template)
+		}
+	}
+}
+
+func TestResourceTemplateLogTimestamps(t *testing.T) {
+	// Given
+	first := "/app/blog/deployment/dev/log/error/?timestamp=1400000000.0"
+	second := "/app/blog/deployment/dev/log/error/?timestamp=1400000042.5"
+
+	// When
+	firstTemplate := resourceTemplate(first)
+	secondTemplate := resourceTemplate(second)
+
+	// Then
+	expected := "/app/{app}/deployment/{dep}/log/{type}/"
+	if firstTemplate != expected || secondTemplate != expected {
+		t.Errorf(msgFail, "resourceTemplate timestamps", expected, firstTemplate+" "+secondTemplate)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	// Given
+	h := NewHistogram([]float64{1, 0.1, 0.5})
+
+	// When
+	h.Observe(0.05)
+	h.Observe(0.3)
+	h.Observe(2)
+
+	// Then
+	if !reflect.DeepEqual(h.Buckets, []float64{0.1, 0.5, 1}) {
+		t.Errorf(msgFail, "Histogram buckets", []float64{0.1, 0.5, 1}, h.Buckets)
+	}
+	if !reflect.DeepEqual(h.Counts, []int{1, 2, 2}) {
+		t.Errorf(msgFail, "Histogram counts", []int{1, 2, 2}, h.Counts)
+	}
+	if h.Count != 3 || h.Sum != 2.35 {
+		t.Errorf(msgFail, "Histogram count and sum", "3 2.35", fmt.Sprint(h.Count, " ", h.Sum))
+	}
+}
+
+func TestMemoryCollector(t *testing.T) {
+	// Given
+	requests := 0
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		switch {
+		case r.URL.Path == "/app/blog/deployment/default/":
+			fmt.Fprint(w, `{"name": "blog/default"}`)
+		case requests%2 == 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})
+	defer server.Close()
+
+	collector := NewMemoryCollector()
+	api.SetCollector(collector)
+	api.Use(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			resp, err := next.RoundTrip(r)
+			if err == nil && resp.StatusCode == http.StatusServiceUnavailable {
+				resp.Body.Close()
+				return next.RoundTrip(r)
+			}
+			return resp, err
+		})
+	})
+
+	// When
+	api.ReadDeployment("blog", "default")
+	api.ReadDeployment("blog", "default")
+	api.ReadAddon("blog", "default", "mysql.free")
+
+	// Then
+	ok := MetricLabels{"ReadDeployment", "GET", "/app/{app}/deployment/{dep}/", "2xx"}
+	notFound := MetricLabels{"ReadAddon", "GET", "/app/{app}/deployment/{dep}/addon/{addon}/", "4xx"}
+	if !reflect.DeepEqual(collector.Labels(), []MetricLabels{notFound, ok}) {
+		t.Fatalf(msgFail, "Labels", []MetricLabels{notFound, ok}, collector.Labels())
+	}
+	if collector.Requests(ok) != 2 {
+		t.Errorf(msgFail, "Requests", 2, collector.Requests(ok))
+	}
+	if collector.Retries(ok) != 0 {
+		t.Errorf(msgFail, "Retries", 0, collector.Retries(ok))
+	}
+	if collector.Requests(notFound) != 1 {
+		t.Errorf(msgFail, "Requests not found", 1, collector.Requests(notFound))
+	}
+	if collector.Retries(notFound) != 1 {
+		t.Errorf(msgFail, "Retries not found", 1, collector.Retries(notFound))
+	}
+	if latency := collector.Latency(ok); latency.Count != 2 || latency.Sum <= 0 {
+		t.Errorf(msgFail, "Latency", 2, latency.Count)
+	}
+	if latency := collector.Latency(MetricLabels{}); latency.Count != 0 {
+		t.Errorf(msgFail, "Latency unknown labels", 0, latency.Count)
+	}
+}
+
+func TestMemoryCollectorTokenRefreshes(t *testing.T) {
+	// Given
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/token/" {
+			fmt.Fprint(w, `{"token": "0987654321", "expires": "2222-3333"}`)
+		}
+	})
+	defer server.Close()
+
+	collector := NewMemoryCollector()
+	api.SetCollector(collector)
+
+	// When
+	api.ClearToken()
+	errCreate := api.CreateToken("user@example.com", "password")
+	valid, errValid := api.IsTokenValid()
+
+	// Then
+	if errCreate != nil || errValid != nil || !valid {
+		t.Fatalf(msgFail, "CreateToken and IsTokenValid", nil, fmt.Sprint(errCreate, errValid))
+	}
+	total, errors := collector.TokenRefreshes()
+	if total != 2 || errors != 0 {
+		t.Errorf(msgFail, "TokenRefreshes", "2 0", fmt.Sprint(total, " ", errors))
+	}
+
+	tokens := MetricLabels{"CreateToken", "POST", "/token/", "2xx"}
+	if collector.Requests(tokens) != 1 {
+		t.Errorf(msgFail, "Requests token", 1, collector.Requests(tokens))
+	}
+}
+
+func TestMetricLabelsString(t *testing.T) {
+	labels := MetricLabels{"ReadApplications", "GET", "/app/", "2xx"}
+	expected := `{operation="ReadApplications",method="GET",resource="/app/",status="2xx"}`
+	if labels.String() != expected {
+		t.Errorf(msgFail, "MetricLabels.String", expected, labels.String())
+	}
+}
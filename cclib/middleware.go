@@ -0,0 +1,67 @@
+package cclib
+
+import (
+	"net/http"
+)
+
+// Middleware wraps the RoundTripper sending the requests
+// of an API. It allows adding cross-cutting behaviour such
+// as custom headers, auditing, metrics, caching or signing:
+//
+//	api.Use(func(next http.RoundTripper) http.RoundTripper {
+//		return cc.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+//			r.Header.Set("X-Request-Id", newRequestId())
+//			return next.RoundTrip(r)
+//		})
+//	})
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc is an adapter to use a function
+// as an http.RoundTripper
+type RoundTripperFunc func(r *http.Request) (*http.Response, error)
+
+// RoundTrip calls f(r)
+func (f RoundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+// chainMiddlewares wraps a RoundTripper with a list of
+// middlewares, the first one being the outermost
+func chainMiddlewares(rt http.RoundTripper, middlewares []Middleware) http.RoundTripper {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		rt = middlewares[i](rt)
+	}
+	return rt
+}
+
+// Use appends middlewares to the API chain. Requests go
+// through them in the order they were added.
+func (api *API) Use(middlewares ...Middleware) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.middlewares = append(api.middlewares, middlewares...)
+}
+
+// Middlewares returns the API middlewares chain
+func (api *API) Middlewares() []Middleware {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	return append([]Middleware{}, api.middlewares...)
+}
+
+// SetTransport sets the RoundTripper sending the API requests,
+// below the middlewares chain, eg: a Recorder or a Replayer.
+// If nil, requests use an http.Transport honouring SslCheck
+// and CaCerts.
+func (api *API) SetTransport(transport http.RoundTripper) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.transport = transport
+}
+
+// Transport returns the API transport
+func (api *API) Transport() http.RoundTripper {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	return api.transport
+}
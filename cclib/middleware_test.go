@@ -0,0 +1,75 @@
+package cclib
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func recordingMiddleware(name string, calls *[]string) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			*calls = append(*calls, name)
+			r.Header.Add("X-Middleware", name)
+			return next.RoundTrip(r)
+		})
+	}
+}
+
+func TestAPIUse(t *testing.T) {
+	// Given
+	var headers []string
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header["X-Middleware"]
+		fmt.Fprint(w, `[]`)
+	})
+	defer server.Close()
+
+	var calls []string
+	api.Use(recordingMiddleware("first", &calls), recordingMiddleware("second", &calls))
+
+	// When
+	_, err := api.ReadApplications()
+
+	// Then
+	if err != nil {
+		t.Errorf(msgFail, "Use", nil, err)
+	}
+	if !reflect.DeepEqual(calls, []string{"first", "second"}) {
+		t.Errorf(msgFail, "Use and order", []string{"first", "second"}, calls)
+	}
+	if !reflect.DeepEqual(headers, []string{"first", "second"}) {
+		t.Errorf(msgFail, "Use and headers", []string{"first", "second"}, headers)
+	}
+	if len(api.Middlewares()) != 2 {
+		t.Errorf(msgFail, "Middlewares", 2, len(api.Middlewares()))
+	}
+}
+
+func TestMiddlewareFaultInjection(t *testing.T) {
+	// Given
+	requests := 0
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	})
+	defer server.Close()
+
+	api.Use(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			return nil, errors.New("injected fault")
+		})
+	})
+
+	// When
+	_, err := api.ReadApplications()
+
+	// Then
+	if err == nil {
+		t.Errorf(msgFail, "Middleware fault injection", "error", err)
+	}
+	if requests != 0 {
+		t.Errorf(msgFail, "Middleware fault injection", 0, requests)
+	}
+}
@@ -0,0 +1,611 @@
+package cclib
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Events of a Notification
+const (
+	// NotifyChange is sent for every ChangeEvent of a Watcher
+	NotifyChange = "change"
+	// NotifyDeploy is sent when a deployment is updated
+	NotifyDeploy = "deploy"
+	// NotifyLogErrors is sent when a LogErrorMonitor finds a burst
+	NotifyLogErrors = "log_errors"
+)
+
+// SignatureHeader contains the HMAC-SHA256 signature of
+// the body of a WebhookSink request: sha256=<hex digest>
+const SignatureHeader = "X-Cclib-Signature"
+
+// Notification contains an event sent to the sinks of a Notifier
+type Notification struct {
+	// Event is NotifyChange, NotifyDeploy or NotifyLogErrors
+	Event       string    `json:"event"`
+	Title       string    `json:"title"`
+	Text        string    `json:"text"`
+	Application string    `json:"application,omitempty"`
+	Deployment  string    `json:"deployment,omitempty"`
+	Time        time.Time `json:"time"`
+	// Data contains the source of the notification, eg:
+	// a ChangeEvent or the deployment updated values
+	Data interface{} `json:"data,omitempty"`
+}
+
+// Sink is an interface that defines where notifications are sent
+type Sink interface {
+	Send(notification Notification) error
+}
+
+// SinkFunc is an adapter to use a function as a Sink
+type SinkFunc func(notification Notification) error
+
+// Send calls f(notification)
+func (f SinkFunc) Send(notification Notification) error {
+	return f(notification)
+}
+
+// NotifyError is returned when some sinks failed
+type NotifyError struct {
+	Notification Notification
+	Errors       []error
+}
+
+func (e *NotifyError) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%s notification failed: %s", e.Notification.Event, strings.Join(messages, "; "))
+}
+
+// DefaultNotifyQueueSize is the number of background
+// notifications a Notifier keeps waiting to be sent
+var DefaultNotifyQueueSize = 100
+
+// Notifier sends notifications to every sink.
+//
+// Notify blocks until every sink is done. Notifications sent on
+// behalf of other code, eg: deployment updates or a Watcher, are
+// queued instead and sent one at a time in the background, so a
+// slow or unresponsive sink never blocks the caller.
+type Notifier struct {
+	Sinks []Sink
+	// OnError receives the errors of the notifications sent
+	// in the background, which are dropped with an error
+	// when QueueSize notifications are already waiting
+	OnError func(error)
+	// QueueSize is the number of background notifications
+	// waiting to be sent, DefaultNotifyQueueSize if 0
+	QueueSize int
+
+	once  sync.Once
+	queue chan queuedNotification
+}
+
+// queuedNotification is a background notification, or
+// a flush marker closing done once it is reached
+type queuedNotification struct {
+	notification Notification
+	done         chan struct{}
+}
+
+// NewNotifier creates a Notifier for sinks.
+//
+// Returns a new Notifier pointer
+func NewNotifier(sinks ...Sink) *Notifier {
+	return &Notifier{Sinks: sinks}
+}
+
+// Notify sends a notification to every sink, setting its time if zero.
+//
+// Returns a *NotifyError if any sink failed.
+func (notifier *Notifier) Notify(notification Notification) error {
+	if notification.Time.IsZero() {
+		notification.Time = time.Now().UTC()
+	}
+
+	var errs []error
+	for _, sink := range notifier.Sinks {
+		if err := sink.Send(notification); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return &NotifyError{notification, errs}
+	}
+	return nil
+}
+
+// ChangeSubscriber returns a function notifying change
+// events, to subscribe to a Watcher
+func (notifier *Notifier) ChangeSubscriber() func(ChangeEvent) {
+	return func(event ChangeEvent) {
+		notifier.notify(Notification{
+			Event:       NotifyChange,
+			Title:       strings.ToUpper(event.Kind[:1]) + event.Kind[1:] + " " + event.Type,
+			Text:        event.String(),
+			Application: event.Application,
+			Deployment:  event.Deployment,
+			Time:        event.Time,
+			Data:        event,
+		})
+	}
+}
+
+// Flush waits until the background notifications
+// queued so far are sent
+func (notifier *Notifier) Flush() {
+	done := make(chan struct{})
+	notifier.start()
+	notifier.queue <- queuedNotification{done: done}
+	<-done
+}
+
+// notify queues a notification to be sent in the background,
+// passing its error to OnError
+func (notifier *Notifier) notify(notification Notification) {
+	if notification.Time.IsZero() {
+		notification.Time = time.Now().UTC()
+	}
+
+	notifier.start()
+	select {
+	case notifier.queue <- queuedNotification{notification: notification}:
+	default:
+		notifier.onError(fmt.Errorf("%s notification dropped: queue is full", notification.Event))
+	}
+}
+
+// start starts the goroutine sending the background notifications
+func (notifier *Notifier) start() {
+	notifier.once.Do(func() {
+		size := notifier.QueueSize
+		if size <= 0 {
+			size = DefaultNotifyQueueSize
+		}
+		notifier.queue = make(chan queuedNotification, size)
+
+		go func() {
+			for queued := range notifier.queue {
+				if queued.done != nil {
+					close(queued.done)
+					continue
+				}
+				notifier.onError(notifier.Notify(queued.notification))
+			}
+		}()
+	})
+}
+
+func (notifier *Notifier) onError(err error) {
+	if err != nil && notifier.OnError != nil {
+		notifier.OnError(err)
+	}
+}
+
+// SetNotifier sets the Notifier of the deployment updates,
+// which are sent in the background without blocking them
+func (api *API) SetNotifier(notifier *Notifier) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.notifier = notifier
+}
+
+// Notifier returns the API Notifier
+func (api *API) Notifier() *Notifier {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	return api.notifier
+}
+
+// notifyDeployment notifies a deployment update, if the API has a Notifier
+func (api *API) notifyDeployment(appName, depName string, values url.Values, rollback bool) {
+	notifier := api.Notifier()
+	if notifier == nil {
+		return
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	changes := make([]string, len(keys))
+	data := make(map[string]string, len(keys))
+	for i, key := range keys {
+		changes[i] = key + " " + values.Get(key)
+		data[key] = values.Get(key)
+	}
+
+	title := appName + "/" + depName + " updated"
+	if rollback {
+		title = appName + "/" + depName + " rolled back"
+	}
+
+	notifier.notify(Notification{
+		Event:       NotifyDeploy,
+		Title:       title,
+		Text:        strings.Join(changes, ", "),
+		Application: appName,
+		Deployment:  depName,
+		Data:        data,
+	})
+}
+
+// WebhookSink posts notifications as JSON to a URL, signed
+// with HMAC-SHA256 in SignatureHeader if Secret is set
+type WebhookSink struct {
+	URL    string
+	Secret string
+	// Client sends the requests, a client with
+	// a 10 seconds timeout if nil
+	Client *http.Client
+}
+
+// Send posts a notification
+func (sink *WebhookSink) Send(notification Notification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return err
+	}
+
+	header := http.Header{}
+	if sink.Secret != "" {
+		header.Set(SignatureHeader, Sign(sink.Secret, body))
+	}
+	return postJSON(sink.Client, sink.URL, body, header)
+}
+
+// Sign returns the signature of a webhook body
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature returns true if signature, the value of
+// SignatureHeader, matches the body, for webhook receivers
+func VerifySignature(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// SlackSink posts notifications to a Slack compatible incoming webhook
+type SlackSink struct {
+	WebhookURL string
+	// Channel and Username override the webhook defaults, optional
+	Channel  string
+	Username string
+	// Client sends the requests, a client with
+	// a 10 seconds timeout if nil
+	Client *http.Client
+}
+
+type slackPayload struct {
+	Text        string            `json:"text"`
+	Channel     string            `json:"channel,omitempty"`
+	Username    string            `json:"username,omitempty"`
+	Attachments []slackAttachment `json:"attachments,omitempty"`
+}
+
+type slackAttachment struct {
+	Color  string       `json:"color,omitempty"`
+	Title  string       `json:"title"`
+	Text   string       `json:"text"`
+	Fields []slackField `json:"fields,omitempty"`
+	Ts     int64        `json:"ts"`
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// slackColors are the attachment colors of each event
+var slackColors = map[string]string{
+	NotifyChange:    "#439FE0",
+	NotifyDeploy:    "good",
+	NotifyLogErrors: "danger",
+}
+
+// Send posts a notification
+func (sink *SlackSink) Send(notification Notification) error {
+	attachment := slackAttachment{
+		Color: slackColors[notification.Event],
+		Title: notification.Title,
+		Text:  notification.Text,
+		Ts:    notification.Time.Unix(),
+	}
+	if notification.Application != "" {
+		attachment.Fields = append(attachment.Fields, slackField{"Application", notification.Application, true})
+	}
+	if notification.Deployment != "" {
+		attachment.Fields = append(attachment.Fields, slackField{"Deployment", notification.Deployment, true})
+	}
+
+	body, err := json.Marshal(slackPayload{
+		Text:        notification.Title,
+		Channel:     sink.Channel,
+		Username:    sink.Username,
+		Attachments: []slackAttachment{attachment},
+	})
+	if err != nil {
+		return err
+	}
+	return postJSON(sink.Client, sink.WebhookURL, body, nil)
+}
+
+// SMTPSink emails notifications
+type SMTPSink struct {
+	// Addr of the SMTP server, eg: smtp.example.com:587
+	Addr string
+	// Auth is optional, eg: smtp.PlainAuth
+	Auth smtp.Auth
+	From string
+	To   []string
+	// SubjectPrefix is prepended to the subjects, optional
+	SubjectPrefix string
+	// Timeout of the whole SMTP session, 10 seconds if 0
+	Timeout time.Duration
+}
+
+// Send emails a notification
+func (sink *SMTPSink) Send(notification Notification) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", sink.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(sink.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s%s\r\n", sink.SubjectPrefix, notification.Title)
+	fmt.Fprintf(&msg, "Date: %s\r\n", notification.Time.Format(time.RFC1123Z))
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	msg.WriteString(notification.Text + "\r\n")
+	if notification.Application != "" {
+		fmt.Fprintf(&msg, "\r\nApplication: %s\r\n", notification.Application)
+	}
+	if notification.Deployment != "" {
+		fmt.Fprintf(&msg, "Deployment: %s\r\n", notification.Deployment)
+	}
+	fmt.Fprintf(&msg, "Event: %s\r\n", notification.Event)
+
+	return sink.sendMail(msg.Bytes())
+}
+
+// sendMail works as smtp.SendMail, which has no timeout,
+// within the sink Timeout
+func (sink *SMTPSink) sendMail(msg []byte) error {
+	timeout := sink.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	conn, err := net.DialTimeout("tcp", sink.Addr, timeout)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if err = conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return err
+	}
+
+	host, _, _ := net.SplitHostPort(sink.Addr)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err = c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return err
+		}
+	}
+	if ok, _ := c.Extension("AUTH"); ok && sink.Auth != nil {
+		if err = c.Auth(sink.Auth); err != nil {
+			return err
+		}
+	}
+
+	if err = c.Mail(sink.From); err != nil {
+		return err
+	}
+	for _, to := range sink.To {
+		if err = c.Rcpt(to); err != nil {
+			return err
+		}
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(msg); err != nil {
+		return err
+	}
+	if err = w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+// postJSON posts a json body and expects a 2xx response
+func postJSON(client *http.Client, url string, body []byte, header http.Header) error {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	r, err := http.NewRequest("POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		r.Header[key] = values
+	}
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("User-Agent", "gocclib/"+Version())
+
+	resp, err := client.Do(r)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return nil
+}
+
+// LogErrorMonitor notifies bursts of entries in the
+// error log of a deployment
+type LogErrorMonitor struct {
+	Api         *API
+	Notifier    *Notifier
+	Application string
+	Deployment  string
+	// Threshold is the number of errors within Window making a burst
+	Threshold int
+	Window    time.Duration
+	// Interval between two log reads, 30 seconds if 0
+	Interval time.Duration
+
+	mu       sync.Mutex
+	last     time.Time
+	errors   []time.Time
+	notified time.Time
+}
+
+// NewLogErrorMonitor creates a LogErrorMonitor notifying 10
+// errors within 5 minutes, reading the log every 30 seconds.
+//
+// Returns a new LogErrorMonitor pointer
+func NewLogErrorMonitor(api *API, notifier *Notifier, appName, depName string) *LogErrorMonitor {
+	return &LogErrorMonitor{
+		Api:         api,
+		Notifier:    notifier,
+		Application: appName,
+		Deployment:  depName,
+		Threshold:   10,
+		Window:      5 * time.Minute,
+		Interval:    30 * time.Second,
+	}
+}
+
+// Poll reads the error log entries newer than the last one
+// read and notifies a burst, in the background, when Threshold
+// errors are found within Window. A burst is notified once,
+// until the errors are out of Window. Notification errors are
+// passed to the Notifier OnError.
+//
+// Returns true if a burst was notified and an error if the log
+// can not be read.
+func (monitor *LogErrorMonitor) Poll() (bool, error) {
+	notification, err := monitor.poll()
+	if err != nil || notification == nil {
+		return false, err
+	}
+
+	monitor.Notifier.notify(*notification)
+	return true, nil
+}
+
+// poll reads the new error log entries and returns
+// the notification of a burst, if any
+func (monitor *LogErrorMonitor) poll() (*Notification, error) {
+	monitor.mu.Lock()
+	defer monitor.mu.Unlock()
+
+	var lastTime *time.Time
+	if !monitor.last.IsZero() {
+		lastTime = &monitor.last
+	}
+	logs, err := monitor.Api.ReadLog(monitor.Application, monitor.Deployment, "error", lastTime)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := monitor.last
+	var lastLog *Log
+	for i, log := range *logs {
+		t := logTime(log)
+		if !t.After(seen) {
+			continue
+		}
+		monitor.errors = append(monitor.errors, t)
+		if t.After(monitor.last) {
+			monitor.last, lastLog = t, &(*logs)[i]
+		}
+	}
+
+	start := time.Now().Add(-monitor.Window)
+	recent := monitor.errors[:0]
+	for _, t := range monitor.errors {
+		if t.After(start) {
+			recent = append(recent, t)
+		}
+	}
+	monitor.errors = recent
+
+	if len(recent) < monitor.Threshold || monitor.notified.After(start) {
+		return nil, nil
+	}
+
+	monitor.notified = time.Now()
+	text := fmt.Sprintf("%d errors in the last %v.", len(recent), monitor.Window)
+	if lastLog != nil {
+		text += " Last one: " + lastLog.Message
+	}
+	return &Notification{
+		Event:       NotifyLogErrors,
+		Title:       fmt.Sprintf("%s/%s error burst", monitor.Application, monitor.Deployment),
+		Text:        text,
+		Application: monitor.Application,
+		Deployment:  monitor.Deployment,
+		Data:        len(recent),
+	}, nil
+}
+
+// Run polls every Interval until stop is closed.
+// Poll errors are passed to onError, if not nil.
+func (monitor *LogErrorMonitor) Run(stop <-chan struct{}, onError func(error)) {
+	interval := monitor.Interval
+	if interval <= 0 {
+		interval = 30 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := monitor.Poll(); err != nil && onError != nil {
+			onError(err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// logTime returns the time of a log entry
+func logTime(log Log) time.Time {
+	sec := int64(log.Time)
+	return time.Unix(sec, int64((log.Time-float64(sec))*1e9)).UTC()
+}
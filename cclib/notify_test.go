@@ -0,0 +1,387 @@
+package cclib
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebhookSink(t *testing.T) {
+	// Given
+	var body []byte
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		signature = r.Header.Get(SignatureHeader)
+	}))
+	defer server.Close()
+
+	sink := &WebhookSink{URL: server.URL, Secret: "s3cr3t"}
+	notification := Notification{Event: NotifyDeploy, Title: "blog/default updated", Application: "blog"}
+
+	// When
+	err := sink.Send(notification)
+
+	// Then
+	if err != nil {
+		t.Fatalf(msgFail, "WebhookSink.Send", nil, err)
+	}
+	if !VerifySignature("s3cr3t", body, signature) {
+		t.Errorf(msgFail, "WebhookSink signature", Sign("s3cr3t", body), signature)
+	}
+	if VerifySignature("other", body, signature) {
+		t.Errorf(msgFail, "VerifySignature with wrong secret", false, true)
+	}
+
+	var received Notification
+	if err = json.Unmarshal(body, &received); err != nil || received.Title != notification.Title {
+		t.Errorf(msgFail, "WebhookSink body", notification.Title, string(body))
+	}
+}
+
+func TestWebhookSinkStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	err := (&WebhookSink{URL: server.URL}).Send(Notification{})
+	if err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf(msgFail, "WebhookSink.Send status", "403", err)
+	}
+}
+
+func TestSlackSink(t *testing.T) {
+	// Given
+	var payload slackPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&payload)
+	}))
+	defer server.Close()
+
+	sink := &SlackSink{WebhookURL: server.URL, Channel: "#deploys"}
+
+	// When
+	err := sink.Send(Notification{
+		Event:       NotifyLogErrors,
+		Title:       "blog/default error burst",
+		Text:        "12 errors",
+		Application: "blog",
+		Deployment:  "default",
+	})
+
+	// Then
+	if err != nil {
+		t.Fatalf(msgFail, "SlackSink.Send", nil, err)
+	}
+	if payload.Text != "blog/default error burst" || payload.Channel != "#deploys" || len(payload.Attachments) != 1 {
+		t.Fatalf(msgFail, "SlackSink payload", "blog/default error burst", payload)
+	}
+	attachment := payload.Attachments[0]
+	if attachment.Color != "danger" || attachment.Text != "12 errors" || len(attachment.Fields) != 2 {
+		t.Errorf(msgFail, "SlackSink attachment", "danger 12 errors", attachment)
+	}
+}
+
+// fakeSMTPServer accepts one mail and sends its data to a channel
+func fakeSMTPServer(t *testing.T) (string, <-chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mails := make(chan string, 1)
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		fmt.Fprint(conn, "220 localhost ESMTP\r\n")
+		var data []string
+		inData := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch {
+			case inData && line == ".\r\n":
+				inData = false
+				mails <- strings.Join(data, "")
+				fmt.Fprint(conn, "250 OK\r\n")
+			case inData:
+				data = append(data, line)
+			case strings.HasPrefix(line, "EHLO"):
+				fmt.Fprint(conn, "250 localhost\r\n")
+			case strings.HasPrefix(line, "DATA"):
+				inData = true
+				fmt.Fprint(conn, "354 Go ahead\r\n")
+			case strings.HasPrefix(line, "QUIT"):
+				fmt.Fprint(conn, "221 Bye\r\n")
+				return
+			default:
+				fmt.Fprint(conn, "250 OK\r\n")
+			}
+		}
+	}()
+
+	return listener.Addr().String(), mails
+}
+
+func TestSMTPSink(t *testing.T) {
+	// Given
+	addr, mails := fakeSMTPServer(t)
+	sink := &SMTPSink{
+		Addr:          addr,
+		From:          "cclib@example.com",
+		To:            []string{"ops@example.com"},
+		SubjectPrefix: "[cclib] ",
+	}
+
+	// When
+	err := sink.Send(Notification{
+		Event:       NotifyDeploy,
+		Title:       "blog/default updated",
+		Text:        "version abc",
+		Application: "blog",
+		Time:        time.Now(),
+	})
+
+	// Then
+	if err != nil {
+		t.Fatalf(msgFail, "SMTPSink.Send", nil, err)
+	}
+	mail := <-mails
+	for _, expected := range []string{"To: ops@example.com", "Subject: [cclib] blog/default updated", "version abc", "Application: blog"} {
+		if !strings.Contains(mail, expected) {
+			t.Errorf(msgFail, "SMTPSink mail", expected, mail)
+		}
+	}
+}
+
+func TestSMTPSinkTimeout(t *testing.T) {
+	// Given
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		// Accepts a connection and never answers
+		if conn, err := listener.Accept(); err == nil {
+			defer conn.Close()
+			time.Sleep(5 * time.Second)
+		}
+	}()
+	sink := &SMTPSink{Addr: listener.Addr().String(), To: []string{"ops@example.com"}, Timeout: 100 * time.Millisecond}
+
+	// When
+	start := time.Now()
+	err = sink.Send(Notification{Event: NotifyDeploy, Title: "blog/default updated"})
+
+	// Then
+	if err == nil {
+		t.Errorf(msgFail, "SMTPSink.Send timeout", "error", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf(msgFail, "SMTPSink.Send timeout", "100ms", elapsed)
+	}
+}
+
+func TestNotifierErrors(t *testing.T) {
+	// Given
+	var sent []string
+	notifier := NewNotifier(
+		SinkFunc(func(n Notification) error { return errors.New("sink down") }),
+		SinkFunc(func(n Notification) error { sent = append(sent, n.Title); return nil }),
+	)
+
+	// When
+	err := notifier.Notify(Notification{Event: NotifyChange, Title: "Addon added"})
+
+	// Then
+	if err == nil || err.Error() != "change notification failed: sink down" {
+		t.Errorf(msgFail, "Notify errors", "change notification failed: sink down", err)
+	}
+	if len(sent) != 1 {
+		t.Errorf(msgFail, "Notify other sinks", 1, len(sent))
+	}
+}
+
+func TestNotifyDeployment(t *testing.T) {
+	// Given
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"name": "blog/default"}`)
+	})
+	defer server.Close()
+
+	var notifications []Notification
+	notifier := NewNotifier(SinkFunc(func(n Notification) error {
+		notifications = append(notifications, n)
+		return nil
+	}))
+	api.SetNotifier(notifier)
+
+	// When
+	_, err := api.UpdateDeployment("blog", "default", "abc", "", "", 2, 0)
+	api.EnableDryRun()
+	api.UpdateDeployment("blog", "default", "def", "", "", 0, 0)
+	notifier.Flush()
+
+	// Then
+	if err != nil {
+		t.Fatalf(msgFail, "UpdateDeployment", nil, err)
+	}
+	if len(notifications) != 1 {
+		t.Fatalf(msgFail, "UpdateDeployment notifications", 1, len(notifications))
+	}
+	n := notifications[0]
+	if n.Event != NotifyDeploy || n.Title != "blog/default updated" || n.Text != "min_boxes 2, version abc" {
+		t.Errorf(msgFail, "UpdateDeployment notification", "blog/default updated: min_boxes 2, version abc", n)
+	}
+}
+
+func TestNotifyDeploymentDoesNotBlock(t *testing.T) {
+	// Given
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"name": "blog/default"}`)
+	})
+	defer server.Close()
+
+	release := make(chan struct{})
+	var errs []error
+	notifier := NewNotifier(SinkFunc(func(n Notification) error {
+		<-release
+		return errors.New("sink down")
+	}))
+	notifier.QueueSize = 1
+	notifier.OnError = func(err error) { errs = append(errs, err) }
+	api.SetNotifier(notifier)
+
+	// When
+	done := make(chan error)
+	go func() {
+		var err error
+		for i := 0; i < 3 && err == nil; i++ {
+			_, err = api.UpdateDeployment("blog", "default", "abc", "", "", 0, 0)
+		}
+		done <- err
+	}()
+
+	// Then
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf(msgFail, "UpdateDeployment", nil, err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf(msgFail, "UpdateDeployment with a blocked sink", "returned", "blocked")
+	}
+
+	close(release)
+	notifier.Flush()
+	if len(errs) != 3 {
+		t.Errorf(msgFail, "OnError with a full queue", 3, errs)
+	}
+}
+
+func TestNotifierChangeSubscriber(t *testing.T) {
+	// Given
+	var notifications []Notification
+	notifier := NewNotifier(SinkFunc(func(n Notification) error {
+		notifications = append(notifications, n)
+		return nil
+	}))
+
+	// When
+	notifier.ChangeSubscriber()(newChangeEvent(ChangeAdded, "addon:blog/default/mysql.free", nil, []byte(`{}`), time.Now()))
+	notifier.Flush()
+
+	// Then
+	if len(notifications) != 1 {
+		t.Fatalf(msgFail, "ChangeSubscriber", 1, len(notifications))
+	}
+	n := notifications[0]
+	if n.Title != "Addon added" || n.Text != "addon blog/default/mysql.free added" || n.Deployment != "default" {
+		t.Errorf(msgFail, "ChangeSubscriber notification", "Addon added", n)
+	}
+}
+
+func TestLogErrorMonitor(t *testing.T) {
+	// Given
+	now := float64(time.Now().Unix())
+	logs := `[]`
+	var queries []string
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Path+"?"+r.URL.RawQuery)
+		fmt.Fprint(w, logs)
+	})
+	defer server.Close()
+
+	var notifications []Notification
+	notifier := NewNotifier(SinkFunc(func(n Notification) error {
+		notifications = append(notifications, n)
+		return nil
+	}))
+	monitor := NewLogErrorMonitor(api, notifier, "blog", "default")
+	monitor.Threshold = 3
+
+	// When
+	logs = fmt.Sprintf(`[{"message": "old", "time": %f}, {"message": "a", "time": %f}, {"message": "b", "time": %f}]`,
+		now-3600, now-2, now-1)
+	first, errFirst := monitor.Poll()
+	logs = fmt.Sprintf(`[{"message": "b", "time": %f}, {"message": "c", "time": %f}]`, now-1, now)
+	second, errSecond := monitor.Poll()
+	logs = fmt.Sprintf(`[{"message": "d", "time": %f}]`, now+1)
+	third, errThird := monitor.Poll()
+	notifier.Flush()
+
+	// Then
+	if errFirst != nil || errSecond != nil || errThird != nil {
+		t.Fatalf(msgFail, "LogErrorMonitor.Poll", nil, fmt.Sprint(errFirst, errSecond, errThird))
+	}
+	if first || !second || third {
+		t.Errorf(msgFail, "LogErrorMonitor bursts", "false true false", fmt.Sprint(first, second, third))
+	}
+	if len(notifications) != 1 || !strings.HasPrefix(notifications[0].Text, "3 errors in the last 5m0s. Last one: c") {
+		t.Errorf(msgFail, "LogErrorMonitor notification", "3 errors in the last 5m0s. Last one: c", notifications)
+	}
+	if queries[0] != "/app/blog/deployment/default/log/error/?" ||
+		!strings.HasPrefix(queries[1], "/app/blog/deployment/default/log/error/?timestamp=") {
+		t.Errorf(msgFail, "LogErrorMonitor log reads", "timestamp after the first read", queries)
+	}
+}
+
+func TestLogErrorMonitorRunWithoutInterval(t *testing.T) {
+	// Given
+	reads := 0
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		reads++
+		fmt.Fprint(w, `[]`)
+	})
+	defer server.Close()
+
+	monitor := &LogErrorMonitor{Api: api, Notifier: NewNotifier(), Application: "blog", Deployment: "default", Threshold: 1}
+	stop := make(chan struct{})
+	close(stop)
+
+	// When
+	monitor.Run(stop, func(err error) { t.Errorf(msgFail, "Run", nil, err) })
+
+	// Then
+	if reads != 1 {
+		t.Errorf(msgFail, "Run without Interval reads", 1, reads)
+	}
+}
@@ -0,0 +1,206 @@
+package cclib
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"time"
+)
+
+// DeploymentProbe checks that a deployment serves requests,
+// eg: HealthChecker.DeploymentProbe
+type DeploymentProbe func(deployment *Deployment) error
+
+// Promotion contains the result of a promotion
+type Promotion struct {
+	App  string
+	From string
+	To   string
+	// Moved contains the aliases which serve To
+	Moved []string
+	// Reverted is true if the aliases were moved back to From
+	Reverted bool
+}
+
+// PromotionError is returned when a promotion fails
+type PromotionError struct {
+	Promotion *Promotion
+	// Stage is "pre-switch", "switch", "post-switch" or "revert"
+	Stage string
+	Err   error
+}
+
+func (e *PromotionError) Error() string {
+	msg := fmt.Sprintf("Promotion of %s/%s failed %s: %v", e.Promotion.App, e.Promotion.To, e.Stage, e.Err)
+	if e.Promotion.Reverted {
+		msg += ", aliases were moved back to " + e.Promotion.From
+	}
+	return msg + "."
+}
+
+// Promoter switches traffic between two deployments of an
+// application, eg: app/blue and app/green, by moving aliases
+type Promoter struct {
+	Api *API
+	// Probe checks the target deployment before and after
+	// the switch, in addition to its state, optional
+	Probe DeploymentProbe
+	// Out receives the promotion progress
+	Out io.Writer
+	// Settle is the time to wait before the post-switch check
+	Settle time.Duration
+	// Revert moves the aliases back if the post-switch check fails
+	Revert bool
+}
+
+// NewPromoter creates a Promoter for an API which probes
+// deployments with a HealthChecker using the default
+// HealthCheck, reverts failed promotions and writes nowhere.
+//
+// Returns a new Promoter pointer
+func NewPromoter(api *API) *Promoter {
+	return &Promoter{
+		Api:    api,
+		Probe:  NewHealthChecker(api, HealthCheck{}).DeploymentProbe(),
+		Out:    ioutil.Discard,
+		Settle: 10 * time.Second,
+		Revert: true,
+	}
+}
+
+// Promote moves aliases from a deployment to another having:
+//
+// * Application name
+//
+// * Name of the deployment serving the aliases
+//
+// * Name of the deployment to promote
+//
+// * Alias names, every custom alias of the first deployment if empty
+//
+// The promoted deployment must be deployed and pass the Probe.
+// Each alias is created on it before being deleted from the other
+// deployment, or deleted just before if both can't have it. Then
+// the promoted deployment is checked again after Settle, and the
+// aliases are moved back if it fails and Revert is set.
+//
+// Returns the Promotion and a *PromotionError if any step fails.
+func (promoter *Promoter) Promote(appName, fromDep, toDep string, aliasNames []string) (*Promotion, error) {
+	promotion := &Promotion{App: appName, From: fromDep, To: toDep}
+
+	if err := promoter.check(appName, toDep); err != nil {
+		return promotion, &PromotionError{promotion, "pre-switch", err}
+	}
+
+	if len(aliasNames) == 0 {
+		aliases, err := promoter.Api.ReadAliases(appName, fromDep)
+		if err != nil {
+			return promotion, &PromotionError{promotion, "pre-switch", err}
+		}
+		for _, alias := range *aliases {
+			if !alias.IsDefault {
+				aliasNames = append(aliasNames, alias.Name)
+			}
+		}
+	}
+
+	for _, aliasName := range aliasNames {
+		if err := promoter.move(appName, aliasName, fromDep, toDep, &promotion.Moved); err != nil {
+			return promotion, promoter.fail(promotion, "switch", err)
+		}
+	}
+
+	if promoter.Settle > 0 {
+		time.Sleep(promoter.Settle)
+	}
+	if err := promoter.check(appName, toDep); err != nil {
+		return promotion, promoter.fail(promotion, "post-switch", err)
+	}
+
+	fmt.Fprintf(promoter.Out, "%s/%s promoted.\n", appName, toDep)
+	return promotion, nil
+}
+
+// check verifies that a deployment is deployed and passes the Probe
+func (promoter *Promoter) check(appName, depName string) error {
+	deployment, err := promoter.Api.ReadDeployment(appName, depName)
+	if err != nil {
+		return err
+	}
+
+	if deployment.State != DeployActive {
+		return fmt.Errorf("%s/%s is %s", appName, depName, deployment.State)
+	}
+
+	if promoter.Probe != nil {
+		if err = promoter.Probe(deployment); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(promoter.Out, "%s/%s is healthy.\n", appName, depName)
+	return nil
+}
+
+// move creates an alias on a deployment and deletes it from
+// another one, deleting it first if creating it fails. The alias
+// is added to moved, if not nil, before it leaves fromDep, and
+// removed from it if it is restored on fromDep.
+func (promoter *Promoter) move(appName, aliasName, fromDep, toDep string, moved *[]string) error {
+	api := promoter.Api
+	record := func(add bool) {
+		if moved == nil {
+			return
+		}
+		if add {
+			*moved = append(*moved, aliasName)
+		} else {
+			*moved = (*moved)[:len(*moved)-1]
+		}
+	}
+
+	if _, err := api.CreateAlias(appName, aliasName, toDep); err == nil {
+		fmt.Fprintf(promoter.Out, "Alias %s added to %s/%s.\n", aliasName, appName, toDep)
+		record(true)
+		if err = api.DeleteAlias(appName, aliasName, fromDep); err != nil && !isNotFound(err) {
+			return err
+		}
+		return nil
+	}
+
+	record(true)
+	if err := api.DeleteAlias(appName, aliasName, fromDep); err != nil && !isNotFound(err) {
+		record(false)
+		return err
+	}
+	if _, err := api.CreateAlias(appName, aliasName, toDep); err != nil {
+		if _, errBack := api.CreateAlias(appName, aliasName, fromDep); errBack != nil {
+			return fmt.Errorf("%v, and alias %s could not be restored: %v", err, aliasName, errBack)
+		}
+		record(false)
+		return err
+	}
+
+	fmt.Fprintf(promoter.Out, "Alias %s moved to %s/%s.\n", aliasName, appName, toDep)
+	return nil
+}
+
+// fail reverts the moved aliases, if Revert is set,
+// and returns a *PromotionError for stage
+func (promoter *Promoter) fail(promotion *Promotion, stage string, err error) error {
+	if !promoter.Revert || len(promotion.Moved) == 0 {
+		return &PromotionError{promotion, stage, err}
+	}
+
+	fmt.Fprintf(promoter.Out, "Promotion failed %s: %v. Reverting.\n", stage, err)
+	for i := len(promotion.Moved) - 1; i >= 0; i-- {
+		aliasName := promotion.Moved[i]
+		if errRevert := promoter.move(promotion.App, aliasName, promotion.To, promotion.From, nil); errRevert != nil {
+			return &PromotionError{promotion, "revert", fmt.Errorf("%v after failing %s: %v", errRevert, stage, err)}
+		}
+		promotion.Moved = promotion.Moved[:i]
+	}
+
+	promotion.Reverted = true
+	return &PromotionError{promotion, stage, err}
+}
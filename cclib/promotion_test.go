@@ -0,0 +1,234 @@
+package cclib
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeAliases serves deployments of an application and aliases
+// which can only belong to one deployment at a time
+type fakeAliases struct {
+	mu         sync.Mutex
+	states     map[string]string
+	subdomains map[string]string
+	aliases    map[string]string
+	// failedPosts is the number of next alias creations failing
+	failedPosts int
+}
+
+func (f *fakeAliases) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// /app/{app}/deployment/{dep}/[alias/[{alias}/]]
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	depName := parts[3]
+
+	switch {
+	case len(parts) == 4:
+		fmt.Fprintf(w, `{"name": "app/%s", "state": "%s", "default_subdomain": "%s"}`,
+			depName, f.states[depName], f.subdomains[depName])
+	case len(parts) == 5 && r.Method == "GET":
+		aliases := []string{fmt.Sprintf(`{"name": "%s.app.com", "is_default": true}`, depName)}
+		for alias, dep := range f.aliases {
+			if dep == depName {
+				aliases = append(aliases, fmt.Sprintf(`{"name": "%s"}`, alias))
+			}
+		}
+		fmt.Fprintf(w, "[%s]", strings.Join(aliases, ","))
+	case len(parts) == 5 && r.Method == "POST" && f.failedPosts > 0:
+		f.failedPosts--
+		w.WriteHeader(http.StatusInternalServerError)
+	case len(parts) == 5 && r.Method == "POST":
+		r.ParseForm()
+		alias := r.PostForm.Get("name")
+		if _, ok := f.aliases[alias]; ok {
+			w.WriteHeader(http.StatusConflict)
+			return
+		}
+		f.aliases[alias] = depName
+		fmt.Fprintf(w, `{"name": "%s"}`, alias)
+	case len(parts) == 6 && r.Method == "DELETE":
+		if f.aliases[parts[5]] != depName {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		delete(f.aliases, parts[5])
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func (f *fakeAliases) aliasesOf(depName string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var aliases []string
+	for alias, dep := range f.aliases {
+		if dep == depName {
+			aliases = append(aliases, alias)
+		}
+	}
+	sort.Strings(aliases)
+	return aliases
+}
+
+func newTestPromoter(greenStatuses ...int) (*Promoter, *fakeAliases, func()) {
+	probes := 0
+	green := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := http.StatusOK
+		if probes < len(greenStatuses) {
+			status = greenStatuses[probes]
+		}
+		probes++
+		w.WriteHeader(status)
+	}))
+
+	fake := &fakeAliases{
+		states:     map[string]string{"blue": DeployActive, "green": DeployActive},
+		subdomains: map[string]string{"blue": "blue.invalid", "green": strings.TrimPrefix(green.URL, "http://")},
+		aliases:    map[string]string{"www.example.com": "blue", "example.com": "blue"},
+	}
+	api, server := newTestAPI(fake.ServeHTTP)
+
+	promoter := NewPromoter(api)
+	promoter.Settle = 0
+	promoter.Probe = NewHealthChecker(api, HealthCheck{Scheme: "http", Path: "/health"}).DeploymentProbe()
+
+	return promoter, fake, func() {
+		server.Close()
+		green.Close()
+	}
+}
+
+func TestPromote(t *testing.T) {
+	// Given
+	promoter, fake, cleanup := newTestPromoter()
+	defer cleanup()
+
+	// When
+	promotion, err := promoter.Promote("app", "blue", "green", nil)
+
+	// Then
+	if err != nil {
+		t.Fatalf(msgFail, "Promote", nil, err)
+	}
+	if len(promotion.Moved) != 2 || promotion.Reverted {
+		t.Errorf(msgFail, "Promote moved", 2, promotion.Moved)
+	}
+	if aliases := fake.aliasesOf("green"); !reflect.DeepEqual(aliases, []string{"example.com", "www.example.com"}) {
+		t.Errorf(msgFail, "Promote green aliases", []string{"example.com", "www.example.com"}, aliases)
+	}
+	if aliases := fake.aliasesOf("blue"); len(aliases) != 0 {
+		t.Errorf(msgFail, "Promote blue aliases", 0, aliases)
+	}
+}
+
+func TestPromoteUnhealthy(t *testing.T) {
+	// Given
+	promoter, fake, cleanup := newTestPromoter(http.StatusServiceUnavailable)
+	defer cleanup()
+
+	// When
+	_, err := promoter.Promote("app", "blue", "green", []string{"www.example.com"})
+
+	// Then
+	perr, ok := err.(*PromotionError)
+	if !ok || perr.Stage != "pre-switch" {
+		t.Fatalf(msgFail, "Promote unhealthy", "pre-switch error", err)
+	}
+	if aliases := fake.aliasesOf("blue"); len(aliases) != 2 {
+		t.Errorf(msgFail, "Promote unhealthy blue aliases", 2, aliases)
+	}
+
+	fake.states["green"] = "not deployed"
+	if _, err = promoter.Promote("app", "blue", "green", nil); err == nil || !strings.Contains(err.Error(), "app/green is not deployed") {
+		t.Errorf(msgFail, "Promote not deployed", "app/green is not deployed", err)
+	}
+}
+
+func TestPromoteHealthRules(t *testing.T) {
+	// Given
+	promoter, _, cleanup := newTestPromoter(http.StatusNotModified)
+	defer cleanup()
+
+	// When
+	_, err := promoter.Promote("app", "blue", "green", []string{"www.example.com"})
+
+	// Then
+	if _, ok := err.(*PromotionError); !ok || !strings.Contains(err.Error(), "304") {
+		t.Errorf(msgFail, "Promote with a 3xx probe", "pre-switch error", err)
+	}
+}
+
+func TestPromoteRevert(t *testing.T) {
+	// Given
+	promoter, fake, cleanup := newTestPromoter(http.StatusOK, http.StatusInternalServerError)
+	defer cleanup()
+
+	// When
+	promotion, err := promoter.Promote("app", "blue", "green", nil)
+
+	// Then
+	perr, ok := err.(*PromotionError)
+	if !ok || perr.Stage != "post-switch" || !promotion.Reverted {
+		t.Fatalf(msgFail, "Promote revert", "reverted post-switch error", err)
+	}
+	if !strings.Contains(err.Error(), "aliases were moved back to blue") {
+		t.Errorf(msgFail, "Promote revert message", "aliases were moved back to blue", err)
+	}
+	if aliases := fake.aliasesOf("blue"); !reflect.DeepEqual(aliases, []string{"example.com", "www.example.com"}) {
+		t.Errorf(msgFail, "Promote revert blue aliases", []string{"example.com", "www.example.com"}, aliases)
+	}
+}
+
+func TestPromoteRevertUnrestoredAlias(t *testing.T) {
+	// Given
+	promoter, fake, cleanup := newTestPromoter()
+	defer cleanup()
+	// Creating the alias on green, retrying once it is deleted
+	// from blue and restoring it on blue fail
+	fake.failedPosts = 3
+
+	// When
+	promotion, err := promoter.Promote("app", "blue", "green", []string{"www.example.com"})
+
+	// Then
+	perr, ok := err.(*PromotionError)
+	if !ok || perr.Stage != "switch" || !promotion.Reverted {
+		t.Fatalf(msgFail, "Promote revert unrestored alias", "reverted switch error", err)
+	}
+	if !strings.Contains(err.Error(), "alias www.example.com could not be restored") {
+		t.Errorf(msgFail, "Promote revert unrestored alias message", "could not be restored", err)
+	}
+	if aliases := fake.aliasesOf("blue"); !reflect.DeepEqual(aliases, []string{"example.com", "www.example.com"}) {
+		t.Errorf(msgFail, "Promote revert unrestored alias blue aliases", []string{"example.com", "www.example.com"}, aliases)
+	}
+	if len(promotion.Moved) != 0 {
+		t.Errorf(msgFail, "Promote revert unrestored alias moved", 0, promotion.Moved)
+	}
+}
+
+func TestPromoteWithoutRevert(t *testing.T) {
+	// Given
+	promoter, fake, cleanup := newTestPromoter(http.StatusOK, http.StatusInternalServerError)
+	defer cleanup()
+	promoter.Revert = false
+
+	// When
+	promotion, err := promoter.Promote("app", "blue", "green", []string{"www.example.com"})
+
+	// Then
+	if err == nil || promotion.Reverted {
+		t.Fatalf(msgFail, "Promote without revert", "error", err)
+	}
+	if aliases := fake.aliasesOf("green"); !reflect.DeepEqual(aliases, []string{"www.example.com"}) {
+		t.Errorf(msgFail, "Promote without revert green aliases", []string{"www.example.com"}, aliases)
+	}
+}
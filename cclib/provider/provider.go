@@ -0,0 +1,317 @@
+/*
+Package provider helps building add-ons for the cloudControl
+platform. It implements the add-on provider protocol as an
+http.Handler which calls a Provider implementation.
+
+Basic usage example:
+
+	package main
+
+	import (
+		"net/http"
+
+		cc "github.com/fern4lvarez/gocclib/cclib"
+		"github.com/fern4lvarez/gocclib/cclib/provider"
+	)
+
+	func main() {
+		var manifest cc.AddonManifest
+		if err := manifest.Read("addon-manifest.json"); err != nil {
+			panic(err)
+		}
+
+		// myProvider implements the provider.Provider interface
+		http.Handle("/", provider.NewHandler(&manifest, myProvider{}))
+		http.ListenAndServe(":4567", nil)
+	}
+*/
+package provider
+
+import (
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	cc "github.com/fern4lvarez/gocclib/cclib"
+)
+
+var (
+	// DefaultResourcesPath is used if the manifest production
+	// base URL has no path
+	DefaultResourcesPath = "/cloudcontrol/resources"
+	// DefaultSsoPath is used if the manifest production
+	// SSO URL has no path
+	DefaultSsoPath = "/sso/login"
+	// MaxTimestampSkew is the maximum age of a SSO timestamp
+	MaxTimestampSkew = 5 * time.Minute
+)
+
+// Provider is the interface an add-on provider implements
+// to handle the requests of the platform
+type Provider interface {
+	// Provision creates a new resource
+	Provision(req ProvisionRequest) (*Response, error)
+	// Deprovision removes the resource with the given id
+	Deprovision(id string) error
+	// ChangePlan moves the resource with the given id to a new plan
+	ChangePlan(id string, plan string) (*Response, error)
+	// Login is called once a SSO request has been verified.
+	// It must write the response, usually setting a session
+	// and redirecting to the add-on dashboard.
+	Login(w http.ResponseWriter, r *http.Request, sso SsoRequest)
+}
+
+// ProvisionRequest contains the information sent by the
+// platform to provision a new resource
+type ProvisionRequest struct {
+	CloudcontrolId string                 `json:"cloudcontrol_id"`
+	Plan           string                 `json:"plan"`
+	CallbackUrl    string                 `json:"callback_url"`
+	Options        map[string]interface{} `json:"options"`
+}
+
+// Response contains the information returned to the platform
+// after provisioning a resource or changing its plan
+type Response struct {
+	// Id identifies the resource on the provider side
+	Id string `json:"id,omitempty"`
+	// Config contains the values of the manifest config_vars
+	Config  map[string]string `json:"config,omitempty"`
+	Message string            `json:"message,omitempty"`
+}
+
+// SsoRequest contains the verified information
+// of a single sign-on request
+type SsoRequest struct {
+	Id        string
+	Email     string
+	NavData   string
+	Timestamp time.Time
+}
+
+// Error can be returned by a Provider to answer
+// with a specific HTTP status
+type Error struct {
+	Status  int
+	Message string
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Handler is an http.Handler implementing the add-on
+// provider protocol
+type Handler struct {
+	Manifest      *cc.AddonManifest
+	Provider      Provider
+	ResourcesPath string
+	SsoPath       string
+	// Now returns the current time, used to verify SSO timestamps
+	Now func() time.Time
+}
+
+// NewHandler creates a Handler having:
+//
+// * Add-on manifest, which provides the id, password and sso_salt
+//
+// * Provider implementation
+//
+// Resources and SSO paths are taken from the manifest production URLs.
+// Platform requests are refused if the manifest has no password, and
+// SSO requests if it has no sso_salt, so a manifest missing them can't
+// be used to forge credentials. See AddonManifest.Validate.
+//
+// Returns a new Handler pointer
+func NewHandler(manifest *cc.AddonManifest, provider Provider) *Handler {
+	return &Handler{
+		Manifest:      manifest,
+		Provider:      provider,
+		ResourcesPath: urlPath(manifest.Api.Production.BaseUrl, DefaultResourcesPath),
+		SsoPath:       urlPath(manifest.Api.Production.SsoUrl, DefaultSsoPath),
+		Now:           time.Now,
+	}
+}
+
+// SsoToken returns the token expected for a SSO request
+// given the resource id, the manifest sso_salt and the timestamp
+func SsoToken(id, salt, timestamp string) string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte(id+":"+salt+":"+timestamp)))
+}
+
+// ServeHTTP dispatches a platform request to the Provider
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimSuffix(r.URL.Path, "/")
+
+	switch {
+	case path == strings.TrimSuffix(h.SsoPath, "/"):
+		h.login(w, r)
+	case path == strings.TrimSuffix(h.ResourcesPath, "/"):
+		if !h.authorize(w, r) {
+			return
+		}
+		if r.Method != "POST" {
+			methodNotAllowed(w, "POST")
+			return
+		}
+		h.provision(w, r)
+	case strings.HasPrefix(path, strings.TrimSuffix(h.ResourcesPath, "/")+"/"):
+		if !h.authorize(w, r) {
+			return
+		}
+		id := strings.TrimPrefix(path, strings.TrimSuffix(h.ResourcesPath, "/")+"/")
+		switch r.Method {
+		case "PUT":
+			h.changePlan(w, r, id)
+		case "DELETE":
+			h.deprovision(w, id)
+		default:
+			methodNotAllowed(w, "PUT, DELETE")
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (h *Handler) authorize(w http.ResponseWriter, r *http.Request) bool {
+	user, password, ok := r.BasicAuth()
+	if ok && h.Manifest.Api.Password != "" &&
+		subtle.ConstantTimeCompare([]byte(user), []byte(h.Manifest.Id)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(password), []byte(h.Manifest.Api.Password)) == 1 {
+		return true
+	}
+
+	w.Header().Set("WWW-Authenticate", `Basic realm="`+h.Manifest.Id+`"`)
+	writeError(w, http.StatusUnauthorized, "Authentication required.")
+	return false
+}
+
+func (h *Handler) provision(w http.ResponseWriter, r *http.Request) {
+	var req ProvisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resp, err := h.Provider.Provision(req)
+	if err != nil {
+		writeProviderError(w, err)
+		return
+	}
+
+	if resp == nil {
+		resp = &Response{}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) changePlan(w http.ResponseWriter, r *http.Request, id string) {
+	var req ProvisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	resp, err := h.Provider.ChangePlan(id, req.Plan)
+	if err != nil {
+		writeProviderError(w, err)
+		return
+	}
+
+	if resp == nil {
+		resp = &Response{}
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+func (h *Handler) deprovision(w http.ResponseWriter, id string) {
+	if err := h.Provider.Deprovision(id); err != nil {
+		writeProviderError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (h *Handler) login(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "POST" {
+		methodNotAllowed(w, "GET, POST")
+		return
+	}
+
+	id := r.FormValue("id")
+	token := r.FormValue("token")
+	timestamp := r.FormValue("timestamp")
+
+	if id == "" || token == "" || timestamp == "" {
+		writeError(w, http.StatusBadRequest, "Parameters id, token and timestamp are required.")
+		return
+	}
+
+	if h.Manifest.Api.SsoSalt == "" {
+		writeError(w, http.StatusForbidden, "Single sign-on is not configured.")
+		return
+	}
+
+	expected := SsoToken(id, h.Manifest.Api.SsoSalt, timestamp)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+		writeError(w, http.StatusForbidden, "Token is not valid.")
+		return
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Timestamp is not valid.")
+		return
+	}
+
+	t := time.Unix(seconds, 0)
+	if skew := h.Now().Sub(t); skew > MaxTimestampSkew || skew < -MaxTimestampSkew {
+		writeError(w, http.StatusForbidden, "Timestamp has expired.")
+		return
+	}
+
+	h.Provider.Login(w, r, SsoRequest{
+		Id:        id,
+		Email:     r.FormValue("email"),
+		NavData:   r.FormValue("nav-data"),
+		Timestamp: t,
+	})
+}
+
+func urlPath(rawurl, defaultPath string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil || u.Path == "" || u.Path == "/" {
+		return defaultPath
+	}
+	return u.Path
+}
+
+func methodNotAllowed(w http.ResponseWriter, allow string) {
+	w.Header().Set("Allow", allow)
+	writeError(w, http.StatusMethodNotAllowed, "Method not allowed.")
+}
+
+func writeProviderError(w http.ResponseWriter, err error) {
+	if e, ok := err.(*Error); ok {
+		writeError(w, e.Status, e.Message)
+		return
+	}
+	writeError(w, http.StatusInternalServerError, err.Error())
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"message": message})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
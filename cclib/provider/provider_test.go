@@ -0,0 +1,197 @@
+package provider
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	cc "github.com/fern4lvarez/gocclib/cclib"
+)
+
+var msgFail = "%v function fails. Expects %v, returns %v"
+
+type fakeProvider struct {
+	provisioned   []ProvisionRequest
+	deprovisioned []string
+	plans         map[string]string
+	logins        []SsoRequest
+}
+
+func (p *fakeProvider) Provision(req ProvisionRequest) (*Response, error) {
+	if req.Plan == "broken" {
+		return nil, &Error{Status: 422, Message: "Plan not available."}
+	}
+	p.provisioned = append(p.provisioned, req)
+	return &Response{Id: "res1", Config: map[string]string{"MY_ADDON_URL": "https://res1.example.com"}}, nil
+}
+
+func (p *fakeProvider) Deprovision(id string) error {
+	p.deprovisioned = append(p.deprovisioned, id)
+	return nil
+}
+
+func (p *fakeProvider) ChangePlan(id string, plan string) (*Response, error) {
+	p.plans[id] = plan
+	return nil, nil
+}
+
+func (p *fakeProvider) Login(w http.ResponseWriter, r *http.Request, sso SsoRequest) {
+	p.logins = append(p.logins, sso)
+	http.Redirect(w, r, "/dashboard", http.StatusFound)
+}
+
+func newTestHandler() (*Handler, *fakeProvider) {
+	manifest := &cc.AddonManifest{
+		Id: "my-addon",
+		Api: cc.AddonManifestApi{
+			Password: "secret",
+			SsoSalt:  "salt",
+			Production: cc.AddonManifestEndpoint{
+				BaseUrl: "https://addon.example.com/cloudcontrol/resources",
+				SsoUrl:  "https://addon.example.com/sso/login",
+			},
+		},
+	}
+	p := &fakeProvider{plans: map[string]string{}}
+	h := NewHandler(manifest, p)
+	h.Now = func() time.Time { return time.Unix(1400000000, 0) }
+	return h, p
+}
+
+func serve(h http.Handler, method, target string, body []byte, auth bool) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, bytes.NewReader(body))
+	if auth {
+		r.SetBasicAuth("my-addon", "secret")
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestProvision(t *testing.T) {
+	// Given
+	h, p := newTestHandler()
+	body := []byte(`{"cloudcontrol_id": "app/default", "plan": "free", "callback_url": "https://api.example.com/cb"}`)
+
+	// When
+	w := serve(h, "POST", "/cloudcontrol/resources", body, true)
+	wUnauth := serve(h, "POST", "/cloudcontrol/resources", body, false)
+	wError := serve(h, "POST", "/cloudcontrol/resources", []byte(`{"plan": "broken"}`), true)
+
+	// Then
+	if w.Code != 200 {
+		t.Errorf(msgFail, "Provision", 200, w.Code)
+	}
+	var resp Response
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if resp.Id != "res1" || resp.Config["MY_ADDON_URL"] != "https://res1.example.com" {
+		t.Errorf(msgFail, "Provision", "res1", resp)
+	}
+	if len(p.provisioned) != 1 || p.provisioned[0].CloudcontrolId != "app/default" {
+		t.Errorf(msgFail, "Provision", "app/default", p.provisioned)
+	}
+	if wUnauth.Code != 401 {
+		t.Errorf(msgFail, "Provision unauthorized", 401, wUnauth.Code)
+	}
+	if wError.Code != 422 {
+		t.Errorf(msgFail, "Provision error", 422, wError.Code)
+	}
+}
+
+func TestDeprovisionAndChangePlan(t *testing.T) {
+	// Given
+	h, p := newTestHandler()
+
+	// When
+	wPlan := serve(h, "PUT", "/cloudcontrol/resources/res1", []byte(`{"plan": "premium"}`), true)
+	wDelete := serve(h, "DELETE", "/cloudcontrol/resources/res1", nil, true)
+	wMethod := serve(h, "GET", "/cloudcontrol/resources/res1", nil, true)
+
+	// Then
+	if wPlan.Code != 200 || p.plans["res1"] != "premium" {
+		t.Errorf(msgFail, "ChangePlan", "premium", p.plans)
+	}
+	if wDelete.Code != 200 || !reflect.DeepEqual(p.deprovisioned, []string{"res1"}) {
+		t.Errorf(msgFail, "Deprovision", []string{"res1"}, p.deprovisioned)
+	}
+	if wMethod.Code != 405 {
+		t.Errorf(msgFail, "ServeHTTP", 405, wMethod.Code)
+	}
+}
+
+func TestLogin(t *testing.T) {
+	// Given
+	h, p := newTestHandler()
+	timestamp := strconv.FormatInt(h.Now().Unix(), 10)
+	expired := strconv.FormatInt(h.Now().Add(-10*time.Minute).Unix(), 10)
+
+	params := url.Values{}
+	params.Add("id", "res1")
+	params.Add("timestamp", timestamp)
+	params.Add("token", SsoToken("res1", "salt", timestamp))
+	params.Add("email", "john@example.org")
+
+	badToken := url.Values{}
+	badToken.Add("id", "res1")
+	badToken.Add("timestamp", timestamp)
+	badToken.Add("token", SsoToken("res1", "other", timestamp))
+
+	oldToken := url.Values{}
+	oldToken.Add("id", "res1")
+	oldToken.Add("timestamp", expired)
+	oldToken.Add("token", SsoToken("res1", "salt", expired))
+
+	// When
+	w := serve(h, "GET", "/sso/login?"+params.Encode(), nil, false)
+	wBad := serve(h, "GET", "/sso/login?"+badToken.Encode(), nil, false)
+	wOld := serve(h, "GET", "/sso/login?"+oldToken.Encode(), nil, false)
+
+	// Then
+	if w.Code != 302 {
+		t.Errorf(msgFail, "Login", 302, w.Code)
+	}
+	if len(p.logins) != 1 || p.logins[0].Email != "john@example.org" {
+		t.Errorf(msgFail, "Login", "john@example.org", p.logins)
+	}
+	if wBad.Code != 403 {
+		t.Errorf(msgFail, "Login bad token", 403, wBad.Code)
+	}
+	if wOld.Code != 403 {
+		t.Errorf(msgFail, "Login expired timestamp", 403, wOld.Code)
+	}
+}
+
+func TestEmptySecretsAreRefused(t *testing.T) {
+	// Given
+	h, p := newTestHandler()
+	h.Manifest.Api.Password = ""
+	h.Manifest.Api.SsoSalt = ""
+	timestamp := strconv.FormatInt(h.Now().Unix(), 10)
+
+	params := url.Values{}
+	params.Add("id", "res1")
+	params.Add("timestamp", timestamp)
+	params.Add("token", SsoToken("res1", "", timestamp))
+
+	r := httptest.NewRequest("POST", "/cloudcontrol/resources", bytes.NewReader([]byte(`{"plan": "free"}`)))
+	r.SetBasicAuth("my-addon", "")
+	wProvision := httptest.NewRecorder()
+
+	// When
+	h.ServeHTTP(wProvision, r)
+	wLogin := serve(h, "GET", "/sso/login?"+params.Encode(), nil, false)
+
+	// Then
+	if wProvision.Code != 401 || len(p.provisioned) != 0 {
+		t.Errorf(msgFail, "Provision without password", 401, wProvision.Code)
+	}
+	if wLogin.Code != 403 || len(p.logins) != 0 {
+		t.Errorf(msgFail, "Login without sso_salt", 403, wLogin.Code)
+	}
+}
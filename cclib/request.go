@@ -2,15 +2,19 @@ package cclib
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
 // Request contains the API request basic information
@@ -20,6 +24,18 @@ type Request struct {
 	SslCheck bool
 	Api      Api
 	CaCerts  *x509.CertPool
+	// Middlewares wrap the transport sending the request
+	Middlewares []Middleware
+	// Transport sends the request, if nil an http.Transport
+	// using SslCheck and CaCerts is used
+	Transport http.RoundTripper
+
+	// anonymous requests are sent without credentials
+	anonymous bool
+	op        *operation
+	tracer    Tracer
+	collector Collector
+	plan      *Plan
 }
 
 // New request creates a new api request having:
@@ -30,14 +46,59 @@ type Request struct {
 //
 // * User token
 //
+// Middlewares, transport, tracer, metrics collector
+// and dry-run plan are taken from the api if it
+// provides them.
+//
 // Returns a new request pointer
 func NewRequest(email string, password string, api Api) *Request {
-	return &Request{
-		email,
-		password,
-		SSL_CHECK,
-		api,
-		CA_CERTS}
+	var middlewares []Middleware
+	if m, ok := api.(interface {
+		Middlewares() []Middleware
+	}); ok {
+		middlewares = m.Middlewares()
+	}
+
+	request := &Request{
+		Email:       email,
+		Password:    password,
+		SslCheck:    SSL_CHECK,
+		Api:         api,
+		CaCerts:     CA_CERTS,
+		Middlewares: middlewares,
+	}
+
+	if s, ok := api.(interface {
+		serve()
+	}); ok {
+		s.serve()
+	}
+
+	if t, ok := api.(interface {
+		Tracer() Tracer
+	}); ok {
+		request.tracer = t.Tracer()
+	}
+
+	if c, ok := api.(interface {
+		Collector() Collector
+	}); ok {
+		request.collector = c.Collector()
+	}
+
+	if t, ok := api.(interface {
+		Transport() http.RoundTripper
+	}); ok {
+		request.Transport = t.Transport()
+	}
+
+	if p, ok := api.(interface {
+		Plan() *Plan
+	}); ok {
+		request.plan = p.Plan()
+	}
+
+	return request
 }
 
 // SetEmail sets email address to a request
@@ -90,6 +151,20 @@ func (request Request) Delete(resource string) ([]byte, error) {
 	return request.do(resource, "DELETE", []byte{}, false, false)
 }
 
+// PostAnonymous makes a POST request without credentials,
+// for resources such as user registration
+func (request Request) PostAnonymous(resource string, data url.Values) ([]byte, error) {
+	request.anonymous = true
+	return request.do(resource, "POST", []byte(data.Encode()), false, false)
+}
+
+// PutAnonymous makes a PUT request without credentials,
+// for resources such as user activation
+func (request Request) PutAnonymous(resource string, data url.Values) ([]byte, error) {
+	request.anonymous = true
+	return request.do(resource, "PUT", []byte(data.Encode()), false, false)
+}
+
 // HeadToken makes an auth HEAD request to a regular
 // endpoint to check if token is still valid
 func (request Request) HeadToken() error {
@@ -118,6 +193,127 @@ func (request Request) doUrl(isTokenReq bool, isAddonReq bool) string {
 }
 
 func (request Request) do(resource string, method string, data []byte, isTokenReq bool, isAddonReq bool) ([]byte, error) {
+	body, err := request.stream(resource, method, data, isTokenReq, isAddonReq)
+	if err != nil {
+		return nil, err
+	}
+
+	defer body.Close()
+	return ioutil.ReadAll(body)
+}
+
+// stream makes a request and returns the response body,
+// which must be closed by the caller. Requests not made
+// by an API method are traced as an operation of their own.
+func (request Request) stream(resource string, method string, data []byte, isTokenReq bool, isAddonReq bool) (io.ReadCloser, error) {
+	if request.op == nil {
+		request.op = startOperation(context.Background(), request.tracer, operationFallback)
+		return request.op.body(request.stream(resource, method, data, isTokenReq, isAddonReq))
+	}
+
+	if request.plan != nil && !isTokenReq && isMutating(method) {
+		return request.plan.record(request.op.name, method, request.resourcePath(resource, isTokenReq, isAddonReq), data), nil
+	}
+
+	ctx := request.op.ctx
+	if request.tracer == nil && request.collector == nil {
+		return request.send(ctx, resource, method, data, isTokenReq, isAddonReq)
+	}
+
+	state := &requestState{operation: request.op.name}
+	ctx = context.WithValue(ctx, requestStateKey{}, state)
+	start := time.Now()
+
+	body, err := request.send(ctx, resource, method, data, isTokenReq, isAddonReq)
+	request.op.trace(state, resource, method)
+
+	if request.collector != nil {
+		request.collector.ObserveRequest(RequestMetric{
+			Labels: MetricLabels{
+				Operation: state.operation,
+				Method:    strings.ToUpper(method),
+				Resource:  resourceTemplate(request.resourcePath(resource, isTokenReq, isAddonReq)),
+				Status:    state.statusClass(),
+			},
+			Duration: time.Since(start),
+			Retries:  state.retries(),
+		})
+	}
+
+	return body, err
+}
+
+// resourcePath returns the path a resource is sent to
+func (request Request) resourcePath(resource string, isTokenReq bool, isAddonReq bool) string {
+	if resource != "" {
+		return resource
+	}
+	if u, err := url.Parse(request.doUrl(isTokenReq, isAddonReq)); err == nil && u.Path != "" {
+		return u.Path
+	}
+	return "/"
+}
+
+// requestState keeps track of the HTTP attempts made by a
+// single API call, retried by middlewares or not
+type requestState struct {
+	operation string
+	attempts  int32
+	status    int32
+}
+
+type requestStateKey struct{}
+
+// defaultTransportKey keeps in the context of a request sent
+// through a custom Transport the one honouring SslCheck and
+// CaCerts, so a Recorder without Next can use it
+type defaultTransportKey struct{}
+
+// attempt returns the number of attempts made so far
+func (state *requestState) attempt() int {
+	return int(atomic.LoadInt32(&state.attempts))
+}
+
+// retries returns the number of attempts after the first one
+func (state *requestState) retries() int {
+	if n := state.attempt() - 1; n > 0 {
+		return n
+	}
+	return 0
+}
+
+// statusClass returns the class of the last response
+// status, eg: 2xx, or "error" if there was no response
+func (state *requestState) statusClass() string {
+	status := atomic.LoadInt32(&state.status)
+	if status == 0 {
+		return "error"
+	}
+	return fmt.Sprintf("%dxx", status/100)
+}
+
+// stateMiddleware counts the attempts and keeps the last
+// response status of the request state found in the context
+func stateMiddleware(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		state, ok := r.Context().Value(requestStateKey{}).(*requestState)
+		if !ok {
+			return next.RoundTrip(r)
+		}
+
+		atomic.AddInt32(&state.attempts, 1)
+		resp, err := next.RoundTrip(r)
+		if err != nil {
+			atomic.StoreInt32(&state.status, 0)
+			return nil, err
+		}
+
+		atomic.StoreInt32(&state.status, int32(resp.StatusCode))
+		return resp, nil
+	})
+}
+
+func (request Request) send(ctx context.Context, resource string, method string, data []byte, isTokenReq bool, isAddonReq bool) (io.ReadCloser, error) {
 	request_url := request.doUrl(isTokenReq, isAddonReq)
 	u, err := url.ParseRequestURI(request_url)
 	if err != nil {
@@ -126,27 +322,46 @@ func (request Request) do(resource string, method string, data []byte, isTokenRe
 
 	if resource != "" {
 		u.Path = resource
+		if i := strings.Index(resource, "?"); i >= 0 {
+			u.Path, u.RawQuery = resource[:i], resource[i+1:]
+		}
 	}
 
 	urlStr := fmt.Sprintf("%v", u)
 
-	tr := &http.Transport{
+	defaultTransport := &http.Transport{
 		TLSClientConfig: &tls.Config{
 			InsecureSkipVerify: !request.SslCheck,
 			RootCAs:            request.CaCerts},
 	}
-	client := &http.Client{Transport: tr}
+	rt := request.Transport
+	if rt == nil {
+		rt = defaultTransport
+	} else {
+		ctx = context.WithValue(ctx, defaultTransportKey{}, http.RoundTripper(defaultTransport))
+	}
+	if request.tracer != nil {
+		rt = tracingMiddleware(request.tracer)(rt)
+	}
+	if request.tracer != nil || request.collector != nil {
+		rt = stateMiddleware(rt)
+	}
+	client := &http.Client{Transport: chainMiddlewares(rt, request.Middlewares)}
 
 	r, err := http.NewRequest(method, urlStr, bytes.NewBuffer(data))
 	if err != nil {
 		return nil, err
 	}
+	r = r.WithContext(ctx)
 
-	if !isNil(request.Api.Token()) {
-		r.Header.Add("Authorization", "cc_auth_token=\""+request.Api.Token().Key+"\"")
-	} else if request.Email != "" && request.Password != "" {
+	token := request.Api.Token()
+	switch {
+	case request.anonymous:
+	case !isNil(token):
+		r.Header.Add("Authorization", "cc_auth_token=\""+token.Key+"\"")
+	case request.Email != "" && request.Password != "":
 		r.SetBasicAuth(request.Email, request.Password)
-	} else {
+	default:
 		return nil, errors.New("Request not authorized.")
 	}
 
@@ -160,7 +375,10 @@ func (request Request) do(resource string, method string, data []byte, isTokenRe
 		r.Header.Add("Content-Type", contentType)
 	}
 	r.Header.Add("Content-Length", strconv.Itoa(len(data)))
-	r.Header.Add("Accept-Encoding", "compress, gzip")
+	// Setting Accept-Encoding disables the transparent gzip
+	// decompression of net/http, bodies are decoded by
+	// decodeContentEncoding instead
+	r.Header.Add("Accept-Encoding", "gzip, deflate")
 
 	if DEBUG {
 		fmt.Printf("DEBUG Request >>> %v\n", r)
@@ -173,17 +391,23 @@ func (request Request) do(resource string, method string, data []byte, isTokenRe
 	}
 
 	if err = checkResponse(resp); err != nil {
+		resp.Body.Close()
 		if DEBUG {
 			fmt.Printf("DEBUG Request Error >>> %v\n", err)
 		}
 		return nil, err
 	}
 
-	defer resp.Body.Close()
 	if DEBUG {
 		fmt.Printf("DEBUG Response >>> %v\n", resp)
 		fmt.Printf("DEBUG Body >>> %v\n", resp.Body)
 	}
 
-	return ioutil.ReadAll(resp.Body)
+	body, err := decodeContentEncoding(resp)
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	return body, nil
 }
@@ -1,6 +1,8 @@
 package cclib
 
 import (
+	"bytes"
+	"compress/gzip"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -15,10 +17,10 @@ func TestRequest(t *testing.T) {
 	password := "password"
 	api := &API{
 		url: "https://api.com",
-		token: &Token{
+		token: &tokenHolder{token: &Token{
 			Key:     "1234567890",
 			Expires: "2222-3333",
-		},
+		}},
 		tokenSourceUrl: "https://api.com/token/",
 	}
 
@@ -63,3 +65,62 @@ func mockHTTP(content []byte) []byte {
 	fmt.Println(string(c))
 	return c
 }
+
+func newTestAPI(handler http.HandlerFunc) (*API, *httptest.Server) {
+	server := httptest.NewServer(handler)
+	api := NewCustomAPI(server.URL, NewToken("1234567890", ""), "", "")
+	return api, server
+}
+
+func TestRequestContentEncoding(t *testing.T) {
+	// Given
+	var acceptEncoding string
+	content := []byte(`[{"name": "app"}]`)
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		acceptEncoding = r.Header.Get("Accept-Encoding")
+		var b bytes.Buffer
+		gz := gzip.NewWriter(&b)
+		gz.Write(content)
+		gz.Close()
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(b.Bytes())
+	})
+	defer server.Close()
+
+	// When
+	apps, err := api.ReadApplications()
+
+	// Then
+	if err != nil {
+		t.Fatalf(msgFail, "ReadApplications", nil, err)
+	}
+	if len(*apps) != 1 || (*apps)[0].Name != "app" {
+		t.Errorf(msgFail, "ReadApplications", "app", apps)
+	}
+	if acceptEncoding != "gzip, deflate" {
+		t.Errorf(msgFail, "Request and Accept-Encoding", "gzip, deflate", acceptEncoding)
+	}
+}
+
+func TestRequestContentEncodingEmptyBody(t *testing.T) {
+	// Given
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		if r.Method == "DELETE" {
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	defer server.Close()
+
+	// When
+	ok, errHead := api.IsTokenValid()
+	errDelete := api.DeleteApplication("app")
+
+	// Then
+	if !ok || errHead != nil {
+		t.Errorf(msgFail, "IsTokenValid with gzip empty body", true, errHead)
+	}
+	if errDelete != nil {
+		t.Errorf(msgFail, "DeleteApplication with gzip empty body", nil, errDelete)
+	}
+}
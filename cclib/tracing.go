@@ -0,0 +1,287 @@
+package cclib
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Tracer is an interface that defines how spans are
+// created, so API calls can be traced by any tracing
+// library, eg: an OpenTelemetry adapter.
+type Tracer interface {
+	// Start creates a span as a child of the span in ctx, if any,
+	// and returns a context containing the new span
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is an interface that defines a traced operation
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// Span names and attributes
+const (
+	SpanAttempt       = "cclib.attempt"
+	AttrApplication   = "cclib.application"
+	AttrDeployment    = "cclib.deployment"
+	AttrResource      = "cclib.resource"
+	AttrRetries       = "cclib.retries"
+	AttrAttempt       = "http.attempt"
+	AttrHTTPMethod    = "http.method"
+	AttrHTTPStatus    = "http.status_code"
+	operationFallback = "Request"
+)
+
+// SetTracer sets the Tracer used to create a span for every API
+// method call and a child span for every HTTP attempt
+func (api *API) SetTracer(tracer Tracer) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.tracer = tracer
+}
+
+// Tracer returns the API Tracer
+func (api *API) Tracer() Tracer {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	return api.tracer
+}
+
+// WithContext returns a copy of the API whose calls are made
+// within ctx, eg: to make their spans children of the caller
+// span or to cancel them. The copy shares the API token, so a
+// token set or cleared in either of them is seen by both.
+func (api *API) WithContext(ctx context.Context) *API {
+	return api.within(&operation{ctx: ctx})
+}
+
+// within returns a copy of the API whose calls are part
+// of op, eg: for API methods calling others
+func (api *API) within(op *operation) *API {
+	api.mu.RLock()
+	defer api.mu.RUnlock()
+	return &API{
+		cache:            api.cache,
+		url:              api.url,
+		token:            api.token,
+		tokenSourceUrl:   api.tokenSourceUrl,
+		registerAddonUrl: api.registerAddonUrl,
+		middlewares:      api.middlewares,
+		call:             op,
+		served:           api.served,
+		tracer:           api.tracer,
+		collector:        api.collector,
+		transport:        api.transport,
+		plan:             api.plan,
+		journal:          api.journal,
+		notifier:         api.notifier,
+	}
+}
+
+// operation is the call of an API method, which names
+// its span and the metrics and plan steps of its requests
+type operation struct {
+	name string
+	ctx  context.Context
+	span Span
+}
+
+// begin starts the operation of the API method name, in
+// a span named after it if the API has a Tracer. It is part
+// of the operation the API calls are made in, if any.
+func (api *API) begin(name string) *operation {
+	ctx := context.Background()
+	if api.call != nil {
+		ctx = api.call.ctx
+	}
+	return startOperation(ctx, api.Tracer(), name)
+}
+
+func startOperation(ctx context.Context, tracer Tracer, name string) *operation {
+	op := &operation{name: name, ctx: ctx}
+	if tracer != nil {
+		op.ctx, op.span = tracer.Start(ctx, "cclib."+name)
+	}
+	return op
+}
+
+// end ends the span of the operation, if any, recording
+// err. Returns err.
+func (op *operation) end(err error) error {
+	if op.span != nil {
+		if err != nil {
+			op.span.RecordError(err)
+		}
+		op.span.End()
+	}
+	return err
+}
+
+// body ends the span of the operation once the response
+// body is closed, or right away if there is an error
+func (op *operation) body(body io.ReadCloser, err error) (io.ReadCloser, error) {
+	if op.span == nil {
+		return body, err
+	}
+	if err != nil {
+		return nil, op.end(err)
+	}
+	if _, ok := body.(*syntheticBody); ok {
+		op.end(nil)
+		return body, nil
+	}
+	return &tracedBody{ReadCloser: body, op: op}, nil
+}
+
+// trace sets the attributes of a request to the span of
+// the operation, if any
+func (op *operation) trace(state *requestState, resource string, method string) {
+	if op.span == nil {
+		return
+	}
+
+	op.span.SetAttribute(AttrHTTPMethod, method)
+	if resource != "" {
+		op.span.SetAttribute(AttrResource, resource)
+	}
+	if appName, depName := resourceNames(resource); appName != "" {
+		op.span.SetAttribute(AttrApplication, appName)
+		if depName != "" {
+			op.span.SetAttribute(AttrDeployment, depName)
+		}
+	}
+	if retries := state.retries(); retries > 0 {
+		op.span.SetAttribute(AttrRetries, retries)
+	}
+}
+
+// tracingMiddleware creates a span for every HTTP attempt
+func tracingMiddleware(tracer Tracer) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			ctx, span := tracer.Start(r.Context(), SpanAttempt)
+			defer span.End()
+
+			span.SetAttribute(AttrHTTPMethod, r.Method)
+			if state, ok := ctx.Value(requestStateKey{}).(*requestState); ok {
+				span.SetAttribute(AttrAttempt, state.attempt())
+			}
+
+			resp, err := next.RoundTrip(r.WithContext(ctx))
+			if err != nil {
+				span.RecordError(err)
+				return nil, err
+			}
+
+			span.SetAttribute(AttrHTTPStatus, resp.StatusCode)
+			if err := checkResponse(resp); err != nil {
+				span.RecordError(err)
+			}
+			return resp, nil
+		})
+	}
+}
+
+// tracedBody ends the operation of a method when its body is closed
+type tracedBody struct {
+	io.ReadCloser
+	op   *operation
+	once sync.Once
+}
+
+func (body *tracedBody) Close() error {
+	err := body.ReadCloser.Close()
+	body.once.Do(func() { body.op.end(nil) })
+	return err
+}
+
+// resourceNames returns the application and deployment
+// names of a resource like /app/{app}/deployment/{dep}/...
+func resourceNames(resource string) (appName, depName string) {
+	parts := strings.Split(strings.Trim(resource, "/"), "/")
+	if len(parts) >= 2 && parts[0] == "app" {
+		appName = parts[1]
+	}
+	if len(parts) >= 4 && parts[2] == "deployment" {
+		depName = parts[3]
+	}
+	return
+}
+
+// RecordingTracer is an in-memory Tracer which keeps every
+// span, useful to test traced code
+type RecordingTracer struct {
+	mu    sync.Mutex
+	spans []*RecordedSpan
+}
+
+// RecordedSpan contains the information of a span
+// created by a RecordingTracer
+type RecordedSpan struct {
+	Name       string
+	Parent     *RecordedSpan
+	Attributes map[string]interface{}
+	Errors     []error
+	Ended      bool
+
+	tracer *RecordingTracer
+}
+
+type recordedSpanKey struct{}
+
+// NewRecordingTracer creates an empty RecordingTracer.
+//
+// Returns a new RecordingTracer pointer
+func NewRecordingTracer() *RecordingTracer {
+	return &RecordingTracer{}
+}
+
+// Start creates a RecordedSpan
+func (tracer *RecordingTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent, _ := ctx.Value(recordedSpanKey{}).(*RecordedSpan)
+	span := &RecordedSpan{
+		Name:       name,
+		Parent:     parent,
+		Attributes: map[string]interface{}{},
+		tracer:     tracer,
+	}
+
+	tracer.mu.Lock()
+	tracer.spans = append(tracer.spans, span)
+	tracer.mu.Unlock()
+
+	return context.WithValue(ctx, recordedSpanKey{}, span), span
+}
+
+// Spans returns every span recorded so far in creation order
+func (tracer *RecordingTracer) Spans() []*RecordedSpan {
+	tracer.mu.Lock()
+	defer tracer.mu.Unlock()
+	return append([]*RecordedSpan{}, tracer.spans...)
+}
+
+// SetAttribute sets a span attribute
+func (span *RecordedSpan) SetAttribute(key string, value interface{}) {
+	span.tracer.mu.Lock()
+	defer span.tracer.mu.Unlock()
+	span.Attributes[key] = value
+}
+
+// RecordError adds an error to the span
+func (span *RecordedSpan) RecordError(err error) {
+	span.tracer.mu.Lock()
+	defer span.tracer.mu.Unlock()
+	span.Errors = append(span.Errors, err)
+}
+
+// End marks the span as ended
+func (span *RecordedSpan) End() {
+	span.tracer.mu.Lock()
+	defer span.tracer.mu.Unlock()
+	span.Ended = true
+}
@@ -0,0 +1,268 @@
+package cclib
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestTracerSpans(t *testing.T) {
+	// Given
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"name": "myapp/default"}`)
+	})
+	defer server.Close()
+
+	tracer := NewRecordingTracer()
+	api.SetTracer(tracer)
+
+	// When
+	_, err := api.ReadDeployment("myapp", "default")
+
+	// Then
+	if err != nil {
+		t.Fatalf(msgFail, "ReadDeployment", nil, err)
+	}
+	spans := tracer.Spans()
+	if len(spans) != 2 {
+		t.Fatalf(msgFail, "Spans", 2, len(spans))
+	}
+
+	method, attempt := spans[0], spans[1]
+	if method.Name != "cclib.ReadDeployment" {
+		t.Errorf(msgFail, "Span name", "cclib.ReadDeployment", method.Name)
+	}
+	if method.Attributes[AttrApplication] != "myapp" {
+		t.Errorf(msgFail, "Span application", "myapp", method.Attributes[AttrApplication])
+	}
+	if method.Attributes[AttrDeployment] != "default" {
+		t.Errorf(msgFail, "Span deployment", "default", method.Attributes[AttrDeployment])
+	}
+	if method.Attributes[AttrHTTPMethod] != "GET" {
+		t.Errorf(msgFail, "Span method", "GET", method.Attributes[AttrHTTPMethod])
+	}
+	if !method.Ended {
+		t.Errorf(msgFail, "Span ended", true, method.Ended)
+	}
+
+	if attempt.Name != SpanAttempt {
+		t.Errorf(msgFail, "Attempt span name", SpanAttempt, attempt.Name)
+	}
+	if attempt.Parent != method {
+		t.Errorf(msgFail, "Attempt span parent", method, attempt.Parent)
+	}
+	if attempt.Attributes[AttrHTTPStatus] != 200 {
+		t.Errorf(msgFail, "Attempt span status", 200, attempt.Attributes[AttrHTTPStatus])
+	}
+	if attempt.Attributes[AttrAttempt] != 1 {
+		t.Errorf(msgFail, "Attempt span attempt", 1, attempt.Attributes[AttrAttempt])
+	}
+}
+
+func TestTracerError(t *testing.T) {
+	// Given
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	defer server.Close()
+
+	tracer := NewRecordingTracer()
+	api.SetTracer(tracer)
+
+	// When
+	_, err := api.ReadApplication("myapp")
+
+	// Then
+	if err == nil {
+		t.Fatalf(msgFail, "ReadApplication", "error", err)
+	}
+	spans := tracer.Spans()
+	if len(spans) != 2 {
+		t.Fatalf(msgFail, "Spans", 2, len(spans))
+	}
+	for _, span := range spans {
+		if len(span.Errors) != 1 {
+			t.Errorf(msgFail, span.Name+" errors", 1, len(span.Errors))
+		}
+		if !span.Ended {
+			t.Errorf(msgFail, span.Name+" ended", true, span.Ended)
+		}
+	}
+	if spans[0].Name != "cclib.ReadApplication" {
+		t.Errorf(msgFail, "Span name", "cclib.ReadApplication", spans[0].Name)
+	}
+	if spans[1].Attributes[AttrHTTPStatus] != 404 {
+		t.Errorf(msgFail, "Attempt span status", 404, spans[1].Attributes[AttrHTTPStatus])
+	}
+}
+
+func TestTracerRetries(t *testing.T) {
+	// Given
+	requests := 0
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, `[]`)
+	})
+	defer server.Close()
+
+	tracer := NewRecordingTracer()
+	api.SetTracer(tracer)
+	api.Use(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			resp, err := next.RoundTrip(r)
+			if err == nil && resp.StatusCode == http.StatusServiceUnavailable {
+				resp.Body.Close()
+				return next.RoundTrip(r)
+			}
+			return resp, err
+		})
+	})
+
+	// When
+	_, err := api.ReadApplications()
+
+	// Then
+	if err != nil {
+		t.Fatalf(msgFail, "ReadApplications", nil, err)
+	}
+	spans := tracer.Spans()
+	if len(spans) != 3 {
+		t.Fatalf(msgFail, "Spans", 3, len(spans))
+	}
+	if spans[0].Attributes[AttrRetries] != 1 {
+		t.Errorf(msgFail, "Span retries", 1, spans[0].Attributes[AttrRetries])
+	}
+	if len(spans[0].Errors) != 0 {
+		t.Errorf(msgFail, "Span errors", 0, len(spans[0].Errors))
+	}
+	if spans[2].Attributes[AttrAttempt] != 2 {
+		t.Errorf(msgFail, "Second attempt", 2, spans[2].Attributes[AttrAttempt])
+	}
+}
+
+func TestTracerCompositeMethod(t *testing.T) {
+	// Given
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			fmt.Fprint(w, `[{"job_id": "job1", "url": "http://app.example.com/old", "interval": "daily"}]`)
+		case "POST":
+			fmt.Fprint(w, `{"job_id": "job2", "url": "http://app.example.com/new", "interval": "hourly"}`)
+		default:
+			w.WriteHeader(204)
+		}
+	})
+	defer server.Close()
+
+	tracer := NewRecordingTracer()
+	api.SetTracer(tracer)
+
+	// When
+	_, err := api.SyncCronjobs("app", "default", []CronjobSpec{{URL: "http://app.example.com/new"}})
+
+	// Then
+	if err != nil {
+		t.Fatalf(msgFail, "SyncCronjobs", nil, err)
+	}
+	var names []string
+	for _, span := range tracer.Spans() {
+		if span.Name == SpanAttempt {
+			continue
+		}
+		names = append(names, span.Name)
+		if span.Name != "cclib.SyncCronjobs" && (span.Parent == nil || span.Parent.Name != "cclib.SyncCronjobs") {
+			t.Errorf(msgFail, span.Name+" parent", "cclib.SyncCronjobs", span.Parent)
+		}
+		if !span.Ended {
+			t.Errorf(msgFail, span.Name+" ended", true, span.Ended)
+		}
+	}
+	expected := []string{"cclib.SyncCronjobs", "cclib.ReadCronjobs", "cclib.CreateCronjobSpec", "cclib.DeleteCronjob"}
+	if !reflect.DeepEqual(names, expected) {
+		t.Errorf(msgFail, "Span names", expected, names)
+	}
+}
+
+func TestAPIWithContext(t *testing.T) {
+	// Given
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	})
+	defer server.Close()
+
+	tracer := NewRecordingTracer()
+	api.SetTracer(tracer)
+	ctx, parent := tracer.Start(context.Background(), "caller")
+
+	// When
+	_, err := api.WithContext(ctx).ReadApplications()
+
+	// Then
+	if err != nil {
+		t.Fatalf(msgFail, "ReadApplications", nil, err)
+	}
+	spans := tracer.Spans()
+	if len(spans) != 3 {
+		t.Fatalf(msgFail, "Spans", 3, len(spans))
+	}
+	if spans[1].Parent != parent {
+		t.Errorf(msgFail, "WithContext and parent", parent, spans[1].Parent)
+	}
+
+	// When
+	_, err = api.ReadApplications()
+
+	// Then
+	spans = tracer.Spans()
+	if err != nil || len(spans) != 5 || spans[3].Parent != nil {
+		t.Errorf(msgFail, "ReadApplications without context", nil, spans[len(spans)-2].Parent)
+	}
+}
+
+func TestAPIWithContextSharesToken(t *testing.T) {
+	// Given
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {})
+	defer server.Close()
+	clone := api.WithContext(context.Background())
+
+	// When
+	api.SetToken("0987654321", "2222-3333")
+
+	// Then
+	if clone.Token().Key != "0987654321" {
+		t.Errorf(msgFail, "WithContext and SetToken", "0987654321", clone.Token().Key)
+	}
+
+	// When
+	clone.ClearToken()
+
+	// Then
+	if api.Token() != nil {
+		t.Errorf(msgFail, "WithContext and ClearToken", nil, api.Token())
+	}
+}
+
+func TestAPIWithContextCanceled(t *testing.T) {
+	// Given
+	api, server := newTestAPI(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[]`)
+	})
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// When
+	_, err := api.WithContext(ctx).ReadApplications()
+
+	// Then
+	if err == nil {
+		t.Errorf(msgFail, "WithContext canceled", "error", err)
+	}
+}
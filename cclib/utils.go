@@ -3,7 +3,9 @@ package cclib
 import (
 	"bufio"
 	"bytes"
+	"compress/flate"
 	"compress/gzip"
+	"compress/zlib"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,32 +14,119 @@ import (
 	"os"
 	"reflect"
 	"strconv"
+	"strings"
 	"time"
-	"unicode/utf8"
 )
 
 var msgFail = "%v function fails. Expects %v, returns %v"
 
-func decodeContent(content []byte) (data interface{}, err error) {
-	if utf8.Valid(content) {
-		json.Unmarshal(content, &data)
-		return data, nil
+// decodeBody decodes a response body into v and closes it
+func decodeBody(body io.ReadCloser, v interface{}) error {
+	defer body.Close()
+	var r io.Reader = body
+	if synthetic, ok := body.(*syntheticBody); ok {
+		r = synthetic.json(v)
 	}
+	return decodeJSON(r, v)
+}
 
-	buf := bytes.NewBuffer(content)
-	reader, err := gzip.NewReader(buf)
+// discardBody closes a response body without reading it
+func discardBody(body io.ReadCloser, err error) error {
 	if err != nil {
-		return nil, err
+		return err
 	}
+	return body.Close()
+}
 
-	err = json.NewDecoder(reader).Decode(&data)
-	if err != nil && err != io.EOF {
-		return nil, err
+// decodeJSON decodes a json stream into v.
+// An empty stream leaves v untouched.
+// Returns an error if the stream is not valid json.
+func decodeJSON(r io.Reader, v interface{}) error {
+	err := json.NewDecoder(r).Decode(v)
+	if err == io.EOF {
+		return nil
 	}
+	return err
+}
 
+// decodedBody closes a decompressing reader
+// together with the response body
+type decodedBody struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (body decodedBody) Close() (err error) {
+	for _, closer := range body.closers {
+		if e := closer.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
 	return
 }
 
+// decodeContentEncoding returns the response body
+// decompressed according to its Content-Encoding header.
+// Responses without content, eg: to a HEAD request or
+// with a 204 status, are returned as they are.
+func decodeContentEncoding(resp *http.Response) (io.ReadCloser, error) {
+	encoding := strings.ToLower(strings.TrimSpace(resp.Header.Get("Content-Encoding")))
+
+	if !hasContent(resp) {
+		return resp.Body, nil
+	}
+
+	switch encoding {
+	case "", "identity":
+		return resp.Body, nil
+	case "gzip", "x-gzip", "deflate":
+		// An empty body is sent by some servers despite
+		// the Content-Encoding header
+		br := bufio.NewReader(resp.Body)
+		header, err := br.Peek(2)
+		if len(header) == 0 && err == io.EOF {
+			return decodedBody{br, []io.Closer{resp.Body}}, nil
+		}
+
+		if encoding != "deflate" {
+			reader, err := gzip.NewReader(br)
+			if err != nil {
+				return nil, err
+			}
+			return decodedBody{reader, []io.Closer{reader, resp.Body}}, nil
+		}
+
+		// deflate should be zlib wrapped but some servers send raw deflate
+		if err == nil && isZlibHeader(header) {
+			reader, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, err
+			}
+			return decodedBody{reader, []io.Closer{reader, resp.Body}}, nil
+		}
+		reader := flate.NewReader(br)
+		return decodedBody{reader, []io.Closer{reader, resp.Body}}, nil
+	}
+
+	return nil, fmt.Errorf("Content-Encoding %s is not supported.", encoding)
+}
+
+// hasContent returns false if a response has no body to decode
+func hasContent(resp *http.Response) bool {
+	if resp.Request != nil && resp.Request.Method == "HEAD" {
+		return false
+	}
+	switch resp.StatusCode {
+	case http.StatusNoContent, http.StatusNotModified:
+		return false
+	}
+	return resp.ContentLength != 0
+}
+
+func isZlibHeader(header []byte) bool {
+	return header[0]&0x0f == 8 && (uint16(header[0])<<8|uint16(header[1]))%31 == 0
+}
+
 func checkResponse(resp *http.Response) (err error) {
 	switch resp.StatusCode {
 	case 200, 201, 204:
@@ -47,6 +136,12 @@ func checkResponse(resp *http.Response) (err error) {
 	}
 }
 
+// isNotFound returns true if err was
+// returned by checkResponse on a 404 response
+func isNotFound(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "404")
+}
+
 func readerToStr(ir io.Reader) string {
 	buf := new(bytes.Buffer)
 	buf.ReadFrom(ir)
@@ -2,74 +2,125 @@ package cclib
 
 import (
 	"bytes"
+	"compress/flate"
 	"compress/gzip"
-	"encoding/json"
+	"compress/zlib"
 	"errors"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"os"
-	"reflect"
 	"testing"
 	"time"
 )
 
-func TestDecodeContentUTF8Valid(t *testing.T) {
+func encodedResponse(encoding string, content []byte) *http.Response {
+	var b bytes.Buffer
+	var w io.WriteCloser
+	switch encoding {
+	case "gzip":
+		w = gzip.NewWriter(&b)
+	case "deflate":
+		w = zlib.NewWriter(&b)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&b, flate.DefaultCompression)
+		encoding = "deflate"
+	default:
+		b.Write(content)
+	}
+	if w != nil {
+		w.Write(content)
+		w.Close()
+	}
+
+	resp := &http.Response{
+		StatusCode:    200,
+		Header:        http.Header{},
+		Body:          ioutil.NopCloser(&b),
+		ContentLength: int64(b.Len()),
+	}
+	resp.Header.Set("Content-Encoding", encoding)
+	return resp
+}
+
+func TestDecodeContentEncoding(t *testing.T) {
 	// Given
 	content := []byte(`{
 					"foo":"abcdefghijklmnopqrstuvxyz",
 				  	"bar":"1234567890"
 				  }`)
 
-	var expectedData interface{}
-	err1 := json.Unmarshal(content, &expectedData)
-
-	// When
-	data, err2 := decodeContent(content)
-
-	// Then
-	if err1 != nil {
-		t.Errorf(msgFail, "decodeContent", nil, err1)
-	}
-	if err2 != nil {
-		t.Errorf(msgFail, "decodeContent", nil, err2)
+	for _, encoding := range []string{"", "identity", "gzip", "deflate", "raw-deflate"} {
+		resp := encodedResponse(encoding, content)
+
+		// When
+		body, err := decodeContentEncoding(resp)
+		var decoded []byte
+		if err == nil {
+			decoded, err = ioutil.ReadAll(body)
+			body.Close()
+		}
+
+		// Then
+		if err != nil {
+			t.Errorf(msgFail, "decodeContentEncoding "+encoding, nil, err)
+		}
+		if !bytes.Equal(decoded, content) {
+			t.Errorf(msgFail, "decodeContentEncoding "+encoding, string(content), string(decoded))
+		}
 	}
-	if !reflect.DeepEqual(data, expectedData) {
-		t.Errorf(msgFail, "decodeContent", expectedData, data)
+}
+
+func TestDecodeContentEncodingEmpty(t *testing.T) {
+	for _, encoding := range []string{"gzip", "deflate"} {
+		// Given
+		unknownLength := encodedResponse(encoding, nil)
+		unknownLength.Body = ioutil.NopCloser(&bytes.Buffer{})
+		unknownLength.ContentLength = -1
+
+		zeroLength := encodedResponse(encoding, nil)
+		zeroLength.Body = ioutil.NopCloser(&bytes.Buffer{})
+		zeroLength.ContentLength = 0
+
+		noContent := encodedResponse(encoding, nil)
+		noContent.Body = ioutil.NopCloser(&bytes.Buffer{})
+		noContent.StatusCode, noContent.ContentLength = 204, -1
+
+		head := encodedResponse(encoding, []byte("{}"))
+		head.Body = ioutil.NopCloser(&bytes.Buffer{})
+		head.Request, _ = http.NewRequest("HEAD", "http://example.com/", nil)
+
+		for _, resp := range []*http.Response{unknownLength, zeroLength, noContent, head} {
+			// When
+			body, err := decodeContentEncoding(resp)
+			var decoded []byte
+			if err == nil {
+				decoded, err = ioutil.ReadAll(body)
+				body.Close()
+			}
+
+			// Then
+			if err != nil {
+				t.Errorf(msgFail, "decodeContentEncoding empty "+encoding, nil, err)
+			}
+			if len(decoded) != 0 {
+				t.Errorf(msgFail, "decodeContentEncoding empty "+encoding, "", string(decoded))
+			}
+		}
 	}
 }
 
-func TestDecodeContentUTF8Invalid(t *testing.T) {
+func TestDecodeContentEncodingUnsupported(t *testing.T) {
 	// Given
-	var b bytes.Buffer
-	w := gzip.NewWriter(&b)
-	w.Write([]byte(`{
-					"foo":"abcdefghijklmnopqrstuvxyz",
-				  	"bar":"1234567890"
-				  }`))
-	w.Close()
-	content := b.Bytes() // gzip content
-
-	c := []byte(`{
-					"foo":"abcdefghijklmnopqrstuvxyz",
-				  	"bar":"1234567890"
-				  }`)
-
-	var expectedData interface{}
-	err1 := json.Unmarshal(c, &expectedData)
+	resp := encodedResponse("br", []byte("{}"))
 
 	// When
-	data, err2 := decodeContent(content)
+	_, err := decodeContentEncoding(resp)
 
 	// Then
-	if err1 != nil {
-		t.Errorf(msgFail, "decodeContent", nil, err1)
-	}
-	if err2 != nil {
-		t.Errorf(msgFail, "decodeContent", nil, err2)
-	}
-	if !reflect.DeepEqual(data, expectedData) {
-		t.Errorf(msgFail, "decodeContent", expectedData, data)
+	if err == nil {
+		t.Errorf(msgFail, "decodeContentEncoding", "error", err)
 	}
 }
 
@@ -205,3 +256,26 @@ func TestIsJsonData(t *testing.T) {
 		t.Errorf(msgFail, "isJsonData", expectedResponse, isJsonDataResponse)
 	}
 }
+
+func TestDecodeJSON(t *testing.T) {
+	// Given
+	var valid, empty, malformed struct {
+		Name string `json:"name"`
+	}
+
+	// When
+	errValid := decodeJSON(bytes.NewBufferString(`{"name": "app"}`), &valid)
+	errEmpty := decodeJSON(bytes.NewBufferString(""), &empty)
+	errMalformed := decodeJSON(bytes.NewBufferString(`{"name": `), &malformed)
+
+	// Then
+	if errValid != nil || valid.Name != "app" {
+		t.Errorf(msgFail, "decodeJSON", "app", valid.Name)
+	}
+	if errEmpty != nil {
+		t.Errorf(msgFail, "decodeJSON", nil, errEmpty)
+	}
+	if errMalformed == nil {
+		t.Errorf(msgFail, "decodeJSON", "error", errMalformed)
+	}
+}
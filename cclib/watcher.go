@@ -0,0 +1,404 @@
+package cclib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Types of change events
+const (
+	ChangeAdded    = "added"
+	ChangeRemoved  = "removed"
+	ChangeModified = "modified"
+)
+
+// Kinds of the resources in a Snapshot
+const (
+	KindApplication = "app"
+	KindDeployment  = "deployment"
+	KindAddon       = "addon"
+	KindAlias       = "alias"
+	KindWorker      = "worker"
+	KindUser        = "user"
+)
+
+// Snapshot contains the state of platform resources, each one
+// stored as json under a key like `addon:app/dep/mysql.free`
+type Snapshot struct {
+	Taken     time.Time                  `json:"taken"`
+	Resources map[string]json.RawMessage `json:"resources"`
+}
+
+// ChangeEvent is emitted when a resource was added,
+// removed or modified between two snapshots
+type ChangeEvent struct {
+	// Type is ChangeAdded, ChangeRemoved or ChangeModified
+	Type string `json:"type"`
+	// Kind is KindApplication, KindDeployment, etc.
+	Kind        string `json:"kind"`
+	Key         string `json:"key"`
+	Application string `json:"application"`
+	// Deployment is empty for applications and application users
+	Deployment string `json:"deployment,omitempty"`
+	// Name of the resource, eg: the add-on or worker name
+	Name string `json:"name"`
+	// Before is nil for added resources
+	Before json.RawMessage `json:"before,omitempty"`
+	// After is nil for removed resources
+	After json.RawMessage `json:"after,omitempty"`
+	Time  time.Time       `json:"time"`
+}
+
+// String returns a short description of the event,
+// eg: "addon blog/default/mysql.free added"
+func (event ChangeEvent) String() string {
+	return fmt.Sprintf("%s %s %s", event.Kind, strings.SplitN(event.Key, ":", 2)[1], event.Type)
+}
+
+// Decode decodes the resource before and after the change
+// into the type of its kind, eg: *Addon for add-ons.
+// Any of them can be nil to be skipped.
+func (event ChangeEvent) Decode(before, after interface{}) error {
+	if before != nil && event.Before != nil {
+		if err := json.Unmarshal(event.Before, before); err != nil {
+			return err
+		}
+	}
+	if after != nil && event.After != nil {
+		if err := json.Unmarshal(event.After, after); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Watcher periodically snapshots applications with their
+// deployments, add-ons, aliases, workers and users, and emits
+// a ChangeEvent to its subscribers for every difference with
+// the previous snapshot, which is persisted between runs
+type Watcher struct {
+	Api *API
+	// Applications to watch, every application if empty
+	Applications []string
+	// StatePath is the file keeping the last snapshot, optional
+	StatePath string
+	// Interval between two snapshots, a minute if 0
+	Interval time.Duration
+
+	mu          sync.Mutex
+	last        *Snapshot
+	subscribers []func(ChangeEvent)
+}
+
+// NewWatcher creates a Watcher for an API which keeps
+// its state in statePath and polls every minute.
+//
+// Returns a new Watcher pointer
+func NewWatcher(api *API, statePath string) *Watcher {
+	return &Watcher{Api: api, StatePath: statePath, Interval: time.Minute}
+}
+
+// Subscribe adds a function called with every change event.
+// Subscribers are called by Poll, so they must not call it.
+func (watcher *Watcher) Subscribe(subscriber func(ChangeEvent)) {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+	watcher.subscribers = append(watcher.subscribers, subscriber)
+}
+
+// Poll takes a snapshot, emits the changes since the previous
+// one and saves it. The first snapshot, without a previous one
+// in memory or in StatePath, emits no change. Resources which
+// could not be read keep their previous state.
+//
+// Returns the emitted events and an error if the snapshot can't
+// be taken or saved, or a *BatchError if some resources failed.
+func (watcher *Watcher) Poll() ([]ChangeEvent, error) {
+	watcher.mu.Lock()
+	defer watcher.mu.Unlock()
+
+	previous := watcher.last
+	if previous == nil && watcher.StatePath != "" {
+		var err error
+		if previous, err = ReadSnapshot(watcher.StatePath); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	current, errs, err := watcher.snapshot()
+	if err != nil {
+		return nil, err
+	}
+	if previous != nil {
+		current.keep(previous, errs)
+	}
+
+	var events []ChangeEvent
+	if previous != nil {
+		events = DiffSnapshots(previous, current)
+	}
+
+	watcher.last = current
+	if watcher.StatePath != "" {
+		if err = current.Write(watcher.StatePath); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, event := range events {
+		for _, subscriber := range watcher.subscribers {
+			subscriber(event)
+		}
+	}
+
+	if len(errs) > 0 {
+		return events, &BatchError{errs}
+	}
+	return events, nil
+}
+
+// Run polls every Interval until stop is closed.
+// Poll errors are passed to onError, if not nil.
+func (watcher *Watcher) Run(stop <-chan struct{}, onError func(error)) {
+	interval := watcher.Interval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := watcher.Poll(); err != nil && onError != nil {
+			onError(err)
+		}
+
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// snapshot reads the watched resources
+func (watcher *Watcher) snapshot() (*Snapshot, []ResourceError, error) {
+	api := watcher.Api
+	snapshot := &Snapshot{Taken: time.Now().UTC(), Resources: map[string]json.RawMessage{}}
+
+	apps, err := api.ReadApplications()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var appNames []string
+	for _, app := range *apps {
+		if len(watcher.Applications) > 0 && !containsString(watcher.Applications, app.Name) {
+			continue
+		}
+		appNames = append(appNames, app.Name)
+		app.Users, app.Deployments = nil, nil
+		snapshot.add(KindApplication, app, app.Name)
+	}
+
+	inventory, err := api.ReadInventory(appNames)
+	if _, ok := err.(*BatchError); err != nil && !ok {
+		return nil, nil, err
+	}
+	errs := inventory.Errors
+
+	for _, app := range inventory.Applications {
+		users, err := api.ReadAppUsers(app.Name)
+		if err != nil {
+			errs = append(errs, ResourceError{app.Name, "", "users", err})
+		} else {
+			for _, user := range *users {
+				snapshot.add(KindUser, user, app.Name, user.Username)
+			}
+		}
+
+		for _, dep := range app.Deployments {
+			depName := deploymentName(dep.Deployment.Name)
+			deployment := dep.Deployment
+			// Billing counters change all the time
+			deployment.BilledAddons, deployment.BilledBoxes = nil, Boxes{}
+			snapshot.add(KindDeployment, deployment, app.Name, depName)
+
+			for _, addon := range dep.Addons {
+				addon.Settings = addon.MaskedSettings()
+				snapshot.add(KindAddon, addon, app.Name, depName, addon.Name)
+			}
+			for _, alias := range dep.Aliases {
+				snapshot.add(KindAlias, alias, app.Name, depName, alias.Name)
+			}
+			for _, worker := range dep.Workers {
+				snapshot.add(KindWorker, worker, app.Name, depName, worker.Id)
+			}
+
+			users, err := api.ReadDeploymentUsers(app.Name, depName)
+			if err != nil {
+				errs = append(errs, ResourceError{app.Name, depName, "users", err})
+				continue
+			}
+			for _, user := range *users {
+				snapshot.add(KindUser, user, app.Name, depName, user.Username)
+			}
+		}
+	}
+
+	return snapshot, errs, nil
+}
+
+// add stores a resource under kind:name/of/the/resource
+func (snapshot *Snapshot) add(kind string, resource interface{}, names ...string) {
+	content, err := json.Marshal(resource)
+	if err != nil {
+		return
+	}
+	snapshot.Resources[kind+":"+strings.Join(names, "/")] = content
+}
+
+// resourceKinds are the kinds of the resources of a ResourceError
+var resourceKinds = map[string]string{
+	"addons":  KindAddon,
+	"aliases": KindAlias,
+	"workers": KindWorker,
+	"users":   KindUser,
+}
+
+// keep copies from previous the resources which could not be read
+func (snapshot *Snapshot) keep(previous *Snapshot, errs []ResourceError) {
+	for _, e := range errs {
+		for key, content := range previous.Resources {
+			kind, path := splitResourceKey(key)
+			if e.Deployment == "" && e.Resource == "deployments" {
+				appUser := kind == KindUser && strings.Count(path, "/") == 1
+				if kind != KindApplication && !appUser && strings.HasPrefix(path, e.Application+"/") {
+					snapshot.Resources[key] = content
+				}
+				continue
+			}
+
+			prefix := e.Application + "/"
+			if e.Deployment != "" {
+				prefix += e.Deployment + "/"
+			}
+			if kind == resourceKinds[e.Resource] && strings.HasPrefix(path, prefix) &&
+				strings.Count(path, "/") == strings.Count(prefix, "/") {
+				snapshot.Resources[key] = content
+			}
+		}
+	}
+}
+
+// DiffSnapshots returns the changes from a snapshot to
+// another one, sorted by key
+func DiffSnapshots(before, after *Snapshot) []ChangeEvent {
+	var events []ChangeEvent
+	for key, old := range before.Resources {
+		current, ok := after.Resources[key]
+		switch {
+		case !ok:
+			events = append(events, newChangeEvent(ChangeRemoved, key, old, nil, after.Taken))
+		case !jsonEqual(old, current):
+			events = append(events, newChangeEvent(ChangeModified, key, old, current, after.Taken))
+		}
+	}
+	for key, current := range after.Resources {
+		if _, ok := before.Resources[key]; !ok {
+			events = append(events, newChangeEvent(ChangeAdded, key, nil, current, after.Taken))
+		}
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].Key < events[j].Key
+	})
+	return events
+}
+
+func newChangeEvent(changeType, key string, before, after json.RawMessage, t time.Time) ChangeEvent {
+	kind, path := splitResourceKey(key)
+	parts := strings.Split(path, "/")
+
+	event := ChangeEvent{
+		Type:        changeType,
+		Kind:        kind,
+		Key:         key,
+		Application: parts[0],
+		Name:        parts[len(parts)-1],
+		Before:      before,
+		After:       after,
+		Time:        t,
+	}
+	if kind == KindDeployment || len(parts) == 3 {
+		event.Deployment = parts[1]
+	}
+	return event
+}
+
+func splitResourceKey(key string) (kind, path string) {
+	parts := strings.SplitN(key, ":", 2)
+	if len(parts) < 2 {
+		return "", key
+	}
+	return parts[0], parts[1]
+}
+
+func jsonEqual(a, b json.RawMessage) bool {
+	var va, vb interface{}
+	if json.Unmarshal(a, &va) != nil || json.Unmarshal(b, &vb) != nil {
+		return string(a) == string(b)
+	}
+	ca, _ := json.Marshal(va)
+	cb, _ := json.Marshal(vb)
+	return string(ca) == string(cb)
+}
+
+// ReadSnapshot reads a snapshot written by Snapshot.Write.
+//
+// Returns the Snapshot and an error if it can't be read or decoded.
+func ReadSnapshot(path string) (*Snapshot, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var snapshot Snapshot
+	if err = decodeJSON(f, &snapshot); err != nil {
+		return nil, err
+	}
+	if snapshot.Resources == nil {
+		snapshot.Resources = map[string]json.RawMessage{}
+	}
+	return &snapshot, nil
+}
+
+// Write writes the snapshot to a file, replacing it atomically
+func (snapshot *Snapshot) Write(path string) error {
+	content, err := json.MarshalIndent(snapshot, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,204 @@
+package cclib
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// fakePlatform serves a single application whose
+// resources can be changed between snapshots
+type fakePlatform struct {
+	mu        sync.Mutex
+	boxes     int
+	addons    string
+	workers   string
+	addonsErr bool
+}
+
+func (f *fakePlatform) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/app/":
+		fmt.Fprint(w, `[{"name": "blog", "type": {"name": "php"}}, {"name": "other"}]`)
+	case "/app/blog/deployment/":
+		fmt.Fprintf(w, `[{"name": "blog/default", "min_boxes": %d, "boxes": {"until": %d}}]`, f.boxes, f.boxes*100)
+	case "/app/blog/deployment/default/addon/":
+		if f.addonsErr {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprint(w, f.addons)
+	case "/app/blog/deployment/default/worker/":
+		fmt.Fprint(w, f.workers)
+	case "/app/blog/deployment/default/alias/":
+		fmt.Fprint(w, `[{"name": "blog.example.com"}]`)
+	case "/app/blog/user/":
+		fmt.Fprint(w, `[{"username": "john", "role": "owner"}]`)
+	case "/app/blog/deployment/default/user/":
+		fmt.Fprint(w, `[]`)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (f *fakePlatform) set(change func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	change()
+}
+
+func TestWatcher(t *testing.T) {
+	// Given
+	dir, err := ioutil.TempDir("", "cclib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	statePath := filepath.Join(dir, "state.json")
+
+	fake := &fakePlatform{
+		boxes:   1,
+		addons:  `[{"name": "mysql.free", "settings": {"MYSQL_PASSWORD": "secret"}}]`,
+		workers: `[{"wrk_id": "wrk1", "command": "run.php"}]`,
+	}
+	api, server := newTestAPI(fake.ServeHTTP)
+	defer server.Close()
+
+	watcher := NewWatcher(api, statePath)
+	watcher.Applications = []string{"blog"}
+	var received []string
+	watcher.Subscribe(func(event ChangeEvent) {
+		received = append(received, event.String())
+	})
+
+	// When
+	first, errFirst := watcher.Poll()
+	fake.set(func() {
+		fake.boxes = 2
+		fake.addons = `[{"name": "mysql.free", "settings": {"MYSQL_PASSWORD": "secret"}}, {"name": "memcachier.dev"}]`
+		fake.workers = `[]`
+	})
+	second, errSecond := watcher.Poll()
+
+	// Then
+	if errFirst != nil || errSecond != nil {
+		t.Fatalf(msgFail, "Poll", nil, fmt.Sprint(errFirst, errSecond))
+	}
+	if len(first) != 0 {
+		t.Errorf(msgFail, "First Poll", 0, first)
+	}
+
+	expected := []string{
+		"addon blog/default/memcachier.dev added",
+		"deployment blog/default modified",
+		"worker blog/default/wrk1 removed",
+	}
+	if !reflect.DeepEqual(received, expected) {
+		t.Fatalf(msgFail, "Poll events", expected, received)
+	}
+
+	var before, after Deployment
+	if err = second[1].Decode(&before, &after); err != nil || before.Containers != 1 || after.Containers != 2 {
+		t.Errorf(msgFail, "ChangeEvent.Decode", "1 -> 2", fmt.Sprint(before.Containers, " -> ", after.Containers, err))
+	}
+	if second[2].Deployment != "default" || second[2].Name != "wrk1" || second[2].After != nil {
+		t.Errorf(msgFail, "Removed event", "default wrk1", second[2])
+	}
+
+	snapshot, err := ReadSnapshot(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := snapshot.Resources["app:other"]; ok {
+		t.Errorf(msgFail, "Snapshot applications", "blog only", snapshot.Resources)
+	}
+	var addon Addon
+	if err = json.Unmarshal(snapshot.Resources["addon:blog/default/mysql.free"], &addon); err != nil || addon.Settings["MYSQL_PASSWORD"] != MaskedValue {
+		t.Errorf(msgFail, "Snapshot masks settings", MaskedValue, addon.Settings)
+	}
+}
+
+func TestWatcherPersistedState(t *testing.T) {
+	// Given
+	dir, err := ioutil.TempDir("", "cclib")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	statePath := filepath.Join(dir, "state.json")
+
+	fake := &fakePlatform{boxes: 1, addons: `[]`, workers: `[]`}
+	api, server := newTestAPI(fake.ServeHTTP)
+	defer server.Close()
+
+	newWatcher := func() *Watcher {
+		watcher := NewWatcher(api, statePath)
+		watcher.Applications = []string{"blog"}
+		return watcher
+	}
+	if _, err = newWatcher().Poll(); err != nil {
+		t.Fatal(err)
+	}
+	fake.set(func() { fake.workers = `[{"wrk_id": "wrk2"}]` })
+
+	// When
+	events, err := newWatcher().Poll()
+
+	// Then
+	if err != nil || len(events) != 1 || events[0].String() != "worker blog/default/wrk2 added" {
+		t.Errorf(msgFail, "Poll with persisted state", "worker blog/default/wrk2 added", fmt.Sprint(events, err))
+	}
+}
+
+func TestWatcherRunWithoutInterval(t *testing.T) {
+	// Given
+	fake := &fakePlatform{boxes: 1, addons: `[]`, workers: `[]`}
+	api, server := newTestAPI(fake.ServeHTTP)
+	defer server.Close()
+
+	watcher := &Watcher{Api: api, Applications: []string{"blog"}}
+	stop := make(chan struct{})
+	close(stop)
+
+	// When
+	watcher.Run(stop, func(err error) { t.Errorf(msgFail, "Run", nil, err) })
+
+	// Then
+	if watcher.last == nil {
+		t.Errorf(msgFail, "Run without Interval", "a snapshot", nil)
+	}
+}
+
+func TestWatcherReadErrors(t *testing.T) {
+	// Given
+	fake := &fakePlatform{boxes: 1, addons: `[{"name": "mysql.free"}]`, workers: `[]`}
+	api, server := newTestAPI(fake.ServeHTTP)
+	defer server.Close()
+
+	watcher := NewWatcher(api, "")
+	watcher.Applications = []string{"blog"}
+	if _, err := watcher.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	fake.set(func() { fake.addonsErr = true })
+
+	// When
+	events, err := watcher.Poll()
+
+	// Then
+	if _, ok := err.(*BatchError); !ok {
+		t.Errorf(msgFail, "Poll read errors", "*BatchError", err)
+	}
+	if len(events) != 0 {
+		t.Errorf(msgFail, "Poll keeps unread resources", 0, events)
+	}
+}